@@ -0,0 +1,32 @@
+package auth
+
+import "strings"
+
+const APIKeyPrefix = "rak_"
+
+// GenerateAPIKey returns a new API key. Like refresh tokens, only its
+// HashAPIKey digest is stored.
+func GenerateAPIKey() (string, error) {
+	secret, err := randomSecret()
+	if err != nil {
+		return "", err
+	}
+	return APIKeyPrefix + secret, nil
+}
+
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+func HashAPIKey(key string) string {
+	return hashSecret(key)
+}
+
+// APIKeyDisplayPrefix is the part of a key kept in plain text so users can
+// tell their keys apart.
+func APIKeyDisplayPrefix(key string) string {
+	if len(key) <= len(APIKeyPrefix)+6 {
+		return key
+	}
+	return key[:len(APIKeyPrefix)+6]
+}
@@ -1,49 +1,134 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var secretKey = []byte(os.Getenv("SECRET_KEY"))
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+	MFATokenTTL     = 5 * time.Minute
+	ResetTokenTTL   = time.Hour
+
+	purposeMFA = "mfa"
+)
+
+func init() {
+	// Sub-second issued-at values let "revoke all sessions" cut off every
+	// token issued before the call without also rejecting one issued
+	// within the same second right after it.
+	jwt.TimePrecision = time.Microsecond
+}
 
 type Claims struct {
-	UserID int `json:"user_id"`
+	UserID  int      `json:"user_id"`
+	Role    string   `json:"role"`
+	Scopes  []string `json:"scopes,omitempty"`
+	Purpose string   `json:"purpose,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID int) (string, error) {
+func GenerateToken(userID int, role string) (string, error) {
+	return generateToken(userID, role, "", AccessTokenTTL)
+}
+
+// GenerateMFAToken issues a short-lived token proving that the password
+// step of a two-factor login succeeded. It is not accepted as an access token.
+func GenerateMFAToken(userID int) (string, error) {
+	return generateToken(userID, "", purposeMFA, MFATokenTTL)
+}
+
+func generateToken(userID int, role, purpose string, ttl time.Duration) (string, error) {
+	jti, err := randomID()
+	if err != nil {
+		return "", err
+	}
+
 	claims := Claims{
-		UserID: userID,
+		UserID:  userID,
+		Role:    role,
+		Purpose: purpose,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(secretKey)
+	return CurrentKeySet().Sign(claims)
+}
+
+func ValidateToken(tokenString string) (*Claims, error) {
+	return validateToken(tokenString, "")
+}
+
+func ValidateMFAToken(tokenString string) (*Claims, error) {
+	return validateToken(tokenString, purposeMFA)
 }
 
-func ValidateToken(tokenString string) (int, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(t *jwt.Token) (interface{}, error) {
-		if t.Method != jwt.SigningMethodHS256 {
-			return nil, fmt.Errorf("unexpected signing method")
-		}
-		return secretKey, nil
-	})
+func validateToken(tokenString, purpose string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, CurrentKeySet().keyFunc,
+		jwt.WithValidMethods([]string{"HS256", "RS256", "EdDSA"}))
 
 	if err != nil {
-		return 0, err
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
 	}
 
 	claims, ok := token.Claims.(*Claims)
-	if !ok || !token.Valid {
-		return 0, ErrInvalidToken
+	if !ok || !token.Valid || claims.ID == "" || claims.IssuedAt == nil || claims.ExpiresAt == nil {
+		return nil, ErrInvalidToken
+	}
+	if claims.Purpose != purpose {
+		return nil, ErrInvalidToken
 	}
 
-	return claims.UserID, nil
+	return claims, nil
+}
+
+// GenerateRefreshToken returns an opaque random token. Only its
+// HashRefreshToken digest is meant to be stored server-side.
+func GenerateRefreshToken() (string, error) {
+	return randomSecret()
+}
+
+func randomID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token ID: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func HashRefreshToken(token string) string {
+	return hashSecret(token)
+}
+
+// GenerateResetToken returns a single-use password reset token; only its
+// HashResetToken digest is stored.
+func GenerateResetToken() (string, error) {
+	return randomSecret()
+}
+
+func HashResetToken(token string) string {
+	return hashSecret(token)
+}
+
+func randomSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashSecret(secret string) string {
+	h := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(h[:])
 }
@@ -2,4 +2,7 @@ package auth
 
 import "errors"
 
-var ErrInvalidToken = errors.New("invlaid token")
+var (
+	ErrInvalidToken = errors.New("invlaid token")
+	ErrRevokedToken = errors.New("revoked token")
+)
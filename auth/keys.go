@@ -0,0 +1,279 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// legacyKID identifies the HS256 key built from SECRET_KEY. Tokens without a
+// kid header were issued before key rotation existed and are checked against it.
+const legacyKID = "default"
+
+const defaultGracePeriod = 24 * time.Hour
+
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	Private   interface{}
+	Public    interface{}
+	RetiredAt time.Time
+}
+
+func NewHS256Key(kid string, secret []byte) *SigningKey {
+	return &SigningKey{ID: kid, Method: jwt.SigningMethodHS256, Private: secret, Public: secret}
+}
+
+func NewRS256Key(kid string, key *rsa.PrivateKey) *SigningKey {
+	return &SigningKey{ID: kid, Method: jwt.SigningMethodRS256, Private: key, Public: &key.PublicKey}
+}
+
+func NewEdDSAKey(kid string, key ed25519.PrivateKey) *SigningKey {
+	return &SigningKey{ID: kid, Method: jwt.SigningMethodEdDSA, Private: key, Public: key.Public()}
+}
+
+// KeySet holds every key tokens may be verified with. One of them is the
+// current signing key; retired keys keep verifying for the grace period.
+type KeySet struct {
+	mu      sync.RWMutex
+	keys    map[string]*SigningKey
+	current string
+	grace   time.Duration
+}
+
+func NewKeySet(grace time.Duration) *KeySet {
+	return &KeySet{keys: make(map[string]*SigningKey), grace: grace}
+}
+
+func (ks *KeySet) Add(key *SigningKey) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	ks.keys[key.ID] = key
+	if ks.current == "" && key.RetiredAt.IsZero() {
+		ks.current = key.ID
+	}
+}
+
+func (ks *KeySet) SetCurrent(kid string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	key, ok := ks.keys[kid]
+	if !ok {
+		return fmt.Errorf("unknown signing key %q", kid)
+	}
+	if !key.RetiredAt.IsZero() {
+		return fmt.Errorf("signing key %q is retired", kid)
+	}
+	ks.current = kid
+	return nil
+}
+
+// Rotate makes key the signing key and retires the previous one.
+func (ks *KeySet) Rotate(key *SigningKey) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if prev, ok := ks.keys[ks.current]; ok {
+		prev.RetiredAt = time.Now()
+	}
+	ks.keys[key.ID] = key
+	ks.current = key.ID
+}
+
+func (ks *KeySet) Retire(kid string, at time.Time) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	key, ok := ks.keys[kid]
+	if !ok {
+		return fmt.Errorf("unknown signing key %q", kid)
+	}
+	key.RetiredAt = at
+	if ks.current == kid {
+		ks.current = ""
+	}
+	return nil
+}
+
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	ks.mu.RLock()
+	key, ok := ks.keys[ks.current]
+	ks.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("no signing key configured")
+	}
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
+
+func (ks *KeySet) keyFunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	if kid == "" {
+		kid = legacyKID
+	}
+
+	// RetiredAt changes under the write lock, so it is checked before
+	// releasing the read lock.
+	ks.mu.RLock()
+	key, ok := ks.keys[kid]
+	usable := ok && ks.usable(key, time.Now())
+	ks.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if t.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s for key %q", t.Method.Alg(), kid)
+	}
+	if !usable {
+		return nil, fmt.Errorf("signing key %q is retired", kid)
+	}
+
+	return key.Public, nil
+}
+
+// usable reports whether key may still verify tokens. The caller must hold
+// ks.mu.
+func (ks *KeySet) usable(key *SigningKey, now time.Time) bool {
+	return key.RetiredAt.IsZero() || now.Before(key.RetiredAt.Add(ks.grace))
+}
+
+var (
+	keySetMu sync.Mutex
+	keySet   *KeySet
+)
+
+// SetKeySet replaces the key set used by GenerateToken and ValidateToken.
+// Passing nil restores the default built from SECRET_KEY.
+func SetKeySet(ks *KeySet) {
+	keySetMu.Lock()
+	defer keySetMu.Unlock()
+
+	keySet = ks
+}
+
+func CurrentKeySet() *KeySet {
+	keySetMu.Lock()
+	defer keySetMu.Unlock()
+
+	if keySet == nil {
+		keySet = NewKeySet(defaultGracePeriod)
+		keySet.Add(NewHS256Key(legacyKID, []byte(os.Getenv("SECRET_KEY"))))
+	}
+	return keySet
+}
+
+// LoadKeySetFromEnv builds a key set from
+//   - SECRET_KEY: HS256 secret, registered with kid "default";
+//   - JWT_KEYS_DIR: directory of PEM private keys (RSA or Ed25519), kid is the file name without extension;
+//   - JWT_SIGNING_KID: kid of the signing key, optional when only one key is configured;
+//   - JWT_RETIRED_KEYS: comma-separated kid=RFC3339 retirement times;
+//   - JWT_KEY_GRACE_PERIOD: how long retired keys keep verifying (default 24h).
+func LoadKeySetFromEnv() (*KeySet, error) {
+	grace := defaultGracePeriod
+	if v := os.Getenv("JWT_KEY_GRACE_PERIOD"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWT_KEY_GRACE_PERIOD: %v", err)
+		}
+		grace = d
+	}
+
+	ks := NewKeySet(grace)
+
+	if secret := os.Getenv("SECRET_KEY"); secret != "" {
+		ks.Add(NewHS256Key(legacyKID, []byte(secret)))
+	}
+
+	if dir := os.Getenv("JWT_KEYS_DIR"); dir != "" {
+		files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list JWT keys: %v", err)
+		}
+		for _, file := range files {
+			kid := strings.TrimSuffix(filepath.Base(file), ".pem")
+			key, err := loadPEMKey(kid, file)
+			if err != nil {
+				return nil, err
+			}
+			ks.Add(key)
+		}
+	}
+
+	if len(ks.keys) == 0 {
+		return nil, fmt.Errorf("no JWT signing keys configured: set SECRET_KEY or JWT_KEYS_DIR")
+	}
+
+	if v := os.Getenv("JWT_RETIRED_KEYS"); v != "" {
+		for _, item := range strings.Split(v, ",") {
+			kid, at, ok := strings.Cut(strings.TrimSpace(item), "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid JWT_RETIRED_KEYS entry %q", item)
+			}
+			retiredAt, err := time.Parse(time.RFC3339, at)
+			if err != nil {
+				return nil, fmt.Errorf("invalid retirement time of key %q: %v", kid, err)
+			}
+			if err := ks.Retire(kid, retiredAt); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if kid := os.Getenv("JWT_SIGNING_KID"); kid != "" {
+		if err := ks.SetCurrent(kid); err != nil {
+			return nil, err
+		}
+	} else if len(ks.keys) > 1 {
+		return nil, fmt.Errorf("JWT_SIGNING_KID must be set when several JWT keys are configured")
+	}
+
+	if ks.current == "" {
+		return nil, fmt.Errorf("no active JWT signing key")
+	}
+
+	return ks, nil
+}
+
+func loadPEMKey(kid, file string) (*SigningKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWT key %q: %v", kid, err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("JWT key %q is not PEM encoded", kid)
+	}
+
+	var parsed interface{}
+	if block.Type == "RSA PRIVATE KEY" {
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JWT key %q: %v", kid, err)
+	}
+
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		return NewRS256Key(kid, key), nil
+	case ed25519.PrivateKey:
+		return NewEdDSAKey(kid, key), nil
+	default:
+		return nil, fmt.Errorf("JWT key %q has unsupported type %T", kid, parsed)
+	}
+}
@@ -0,0 +1,49 @@
+package auth
+
+type Permission string
+
+const (
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+
+	PermTasksRead   Permission = "tasks:read"
+	PermTasksWrite  Permission = "tasks:write"
+	PermTasksAll    Permission = "tasks:all"
+	PermUsersManage Permission = "users:manage"
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleMember: {PermTasksRead, PermTasksWrite},
+	RoleAdmin:  {PermTasksRead, PermTasksWrite, PermTasksAll, PermUsersManage},
+}
+
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+func RoleHasPermission(role string, perm Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermission reports whether the role grants perm and, for API key
+// requests, whether the key was issued with that scope.
+func (c *Claims) HasPermission(perm Permission) bool {
+	if !RoleHasPermission(c.Role, perm) {
+		return false
+	}
+	if c.Scopes == nil {
+		return true
+	}
+	for _, scope := range c.Scopes {
+		if Permission(scope) == perm {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,21 @@
+package basic_types
+
+import "time"
+
+// MaxAttachmentSize is the maximum size of an attached file in bytes.
+const MaxAttachmentSize = 10 << 20
+
+// Attachment describes a file attached to a task. The contents are kept in
+// a blob store under BlobKey. ContentType is sniffed from the contents on
+// upload; UploaderID is nil if the uploader no longer exists.
+type Attachment struct {
+	ID          int       `json:"id"`
+	OwnerID     int       `json:"-"`
+	TaskID      int       `json:"task_id"`
+	UploaderID  *int      `json:"uploader_id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	BlobKey     string    `json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,18 @@
+package basic_types
+
+import "time"
+
+// MaxCommentLength is the maximum length of a comment body in characters.
+const MaxCommentLength = 10000
+
+// Comment is a message in the discussion of a task. AuthorID is nil if the
+// author no longer exists; EditedAt is set once the body has been changed.
+type Comment struct {
+	ID        int        `json:"id"`
+	OwnerID   int        `json:"-"`
+	TaskID    int        `json:"task_id"`
+	AuthorID  *int       `json:"author_id"`
+	Body      string     `json:"body"`
+	CreatedAt time.Time  `json:"created_at"`
+	EditedAt  *time.Time `json:"edited_at,omitempty"`
+}
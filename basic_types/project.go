@@ -0,0 +1,14 @@
+package basic_types
+
+import "time"
+
+// Project groups tasks of one owner. Tasks without a project are in the
+// owner's inbox.
+type Project struct {
+	ID          int       `json:"id"`
+	OwnerID     int       `json:"-"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
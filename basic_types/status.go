@@ -0,0 +1,137 @@
+package basic_types
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	StatusTodo       = "todo"
+	StatusInProgress = "in_progress"
+	StatusBlocked    = "blocked"
+	StatusDone       = "done"
+	StatusCancelled  = "cancelled"
+)
+
+var statuses = []string{StatusTodo, StatusInProgress, StatusBlocked, StatusDone, StatusCancelled}
+
+// DefaultTransitions lists the statuses a task may move to from each status.
+// Staying in the same status is always allowed.
+var DefaultTransitions = map[string][]string{
+	StatusTodo:       {StatusInProgress, StatusBlocked, StatusDone, StatusCancelled},
+	StatusInProgress: {StatusTodo, StatusBlocked, StatusDone, StatusCancelled},
+	StatusBlocked:    {StatusTodo, StatusInProgress, StatusCancelled},
+	StatusDone:       {StatusTodo},
+	StatusCancelled:  {StatusTodo},
+}
+
+var (
+	transitionsMu sync.RWMutex
+	transitions   = DefaultTransitions
+)
+
+func IsValidStatus(status string) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// SetTransitions replaces the allowed-transitions table. A nil table restores
+// DefaultTransitions.
+func SetTransitions(table map[string][]string) error {
+	if table == nil {
+		table = DefaultTransitions
+	}
+	for from, targets := range table {
+		if !IsValidStatus(from) {
+			return fmt.Errorf("unknown status %q", from)
+		}
+		for _, to := range targets {
+			if !IsValidStatus(to) {
+				return fmt.Errorf("unknown status %q", to)
+			}
+		}
+	}
+
+	transitionsMu.Lock()
+	transitions = table
+	transitionsMu.Unlock()
+	return nil
+}
+
+// ParseTransitions reads a table written as "from:to|to;from:to", e.g.
+// "todo:in_progress|cancelled;in_progress:done".
+func ParseTransitions(s string) (map[string][]string, error) {
+	table := make(map[string][]string)
+	for _, rule := range strings.Split(s, ";") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		from, targets, ok := strings.Cut(rule, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid transition rule %q", rule)
+		}
+		from = strings.TrimSpace(from)
+		table[from] = nil
+		for _, to := range strings.Split(targets, "|") {
+			if to = strings.TrimSpace(to); to != "" {
+				table[from] = append(table[from], to)
+			}
+		}
+	}
+	return table, nil
+}
+
+// LoadTransitionsFromEnv applies TASK_STATUS_TRANSITIONS if it is set.
+func LoadTransitionsFromEnv() error {
+	s := os.Getenv("TASK_STATUS_TRANSITIONS")
+	if s == "" {
+		return nil
+	}
+	table, err := ParseTransitions(s)
+	if err != nil {
+		return err
+	}
+	return SetTransitions(table)
+}
+
+func CanTransition(from, to string) bool {
+	if from == to {
+		return true
+	}
+
+	transitionsMu.RLock()
+	defer transitionsMu.RUnlock()
+
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyStatus moves the task to status and maintains its timestamps:
+// StartedAt is set the first time work starts, CompletedAt is set on done and
+// cleared when the task leaves it.
+func (t *Task) ApplyStatus(status string, now time.Time) {
+	t.Status = status
+
+	if status == StatusInProgress && t.StartedAt == nil {
+		t.StartedAt = &now
+	}
+	if status == StatusDone {
+		if t.CompletedAt == nil {
+			t.CompletedAt = &now
+		}
+	} else {
+		t.CompletedAt = nil
+	}
+}
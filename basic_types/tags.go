@@ -0,0 +1,54 @@
+package basic_types
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limits on the free-form labels attached to a task.
+const (
+	MaxTagLength   = 50
+	MaxTagsPerTask = 20
+)
+
+// NormalizeTag trims a tag and checks that it is neither empty nor longer
+// than MaxTagLength characters.
+func NormalizeTag(tag string) (string, error) {
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return "", fmt.Errorf("tag must not be empty")
+	}
+	if utf8.RuneCountInString(tag) > MaxTagLength {
+		return "", fmt.Errorf("tag %q is longer than %d characters", tag, MaxTagLength)
+	}
+	return tag, nil
+}
+
+// NormalizeTags normalizes every tag, drops duplicates and sorts the result,
+// which is the order stores return tags in. A nil slice stays nil.
+func NormalizeTags(tags []string) ([]string, error) {
+	if tags == nil {
+		return nil, nil
+	}
+
+	seen := make(map[string]bool, len(tags))
+	result := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag, err := NormalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			result = append(result, tag)
+		}
+	}
+
+	if len(result) > MaxTagsPerTask {
+		return nil, fmt.Errorf("a task can have at most %d tags", MaxTagsPerTask)
+	}
+	sort.Strings(result)
+	return result, nil
+}
@@ -1,7 +1,28 @@
 package basic_types
 
+import "time"
+
+// Task priorities range from MinPriority (none) to MaxPriority (most urgent).
+const (
+	MinPriority = 0
+	MaxPriority = 5
+)
+
 type Task struct {
-	ID          int    `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	ID          int        `json:"id"`
+	OwnerID     int        `json:"-"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Status      string     `json:"status"`
+	Priority    int        `json:"priority"`
+	ProjectID   *int       `json:"project_id,omitempty"`
+	ParentID    *int       `json:"parent_id,omitempty"`
+	Tags        []string   `json:"tags"`
+	DueAt       *time.Time `json:"due_at,omitempty"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	Version     int        `json:"version"`
 }
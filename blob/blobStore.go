@@ -0,0 +1,63 @@
+package blob
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+)
+
+// BlobStore keeps the contents of attachments. Their metadata lives in the
+// task store; a blob is only addressed by its key.
+type BlobStore interface {
+	// Put stores size bytes read from r under key.
+	Put(key string, r io.Reader, size int64) error
+	// Open returns the blob stored under key, or ErrBlobNotFound.
+	Open(key string) (io.ReadSeekCloser, error)
+	// Delete removes the blob stored under key. Deleting a missing blob is
+	// not an error.
+	Delete(key string) error
+}
+
+// NewKey returns a new random key under prefix.
+func NewKey(prefix string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate blob key: %v", err)
+	}
+	return path.Join(prefix, hex.EncodeToString(b)), nil
+}
+
+// NewBlobStoreFromEnv picks the blob store configured by BLOB_STORE:
+//   - "local" (default) keeps blobs in the directory BLOB_DIR (default
+//     "attachments").
+//   - "s3" keeps them in the bucket S3_BUCKET of the S3-compatible service at
+//     S3_ENDPOINT, signing requests with S3_ACCESS_KEY and S3_SECRET_KEY for
+//     S3_REGION (default "us-east-1").
+func NewBlobStoreFromEnv() (BlobStore, error) {
+	switch kind := os.Getenv("BLOB_STORE"); kind {
+	case "", "local":
+		dir := os.Getenv("BLOB_DIR")
+		if dir == "" {
+			dir = "attachments"
+		}
+		return NewLocalStore(dir), nil
+	case "s3":
+		config := map[string]string{}
+		for _, env := range []string{"S3_ENDPOINT", "S3_BUCKET", "S3_ACCESS_KEY", "S3_SECRET_KEY"} {
+			if config[env] = os.Getenv(env); config[env] == "" {
+				return nil, fmt.Errorf("%s must be set for the s3 blob store", env)
+			}
+		}
+		region := os.Getenv("S3_REGION")
+		if region == "" {
+			region = "us-east-1"
+		}
+		return NewS3Store(config["S3_ENDPOINT"], config["S3_BUCKET"], region,
+			config["S3_ACCESS_KEY"], config["S3_SECRET_KEY"]), nil
+	default:
+		return nil, fmt.Errorf("unknown blob store %q", kind)
+	}
+}
@@ -0,0 +1,86 @@
+package blob
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// LocalStore keeps blobs as files under a directory, one file per key.
+type LocalStore struct {
+	dir string
+}
+
+func NewLocalStore(dir string) *LocalStore {
+	return &LocalStore{dir: dir}
+}
+
+func (ls *LocalStore) path(key string) (string, error) {
+	if !filepath.IsLocal(filepath.FromSlash(key)) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidBlobKey, key)
+	}
+	return filepath.Join(ls.dir, filepath.FromSlash(key)), nil
+}
+
+// Put writes the blob to a temporary file first, so that a failed upload
+// never leaves a partial blob under key.
+func (ls *LocalStore) Put(key string, r io.Reader, size int64) error {
+	name, err := ls.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+		return fmt.Errorf("failed to create blob directory: %v", err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create blob file: %v", err)
+	}
+	defer os.Remove(f.Name())
+
+	n, err := io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write blob %s: %v", key, err)
+	}
+	if n != size {
+		return fmt.Errorf("failed to write blob %s: got %d bytes, expected %d", key, n, size)
+	}
+
+	if err := os.Rename(f.Name(), name); err != nil {
+		return fmt.Errorf("failed to write blob %s: %v", key, err)
+	}
+	return nil
+}
+
+func (ls *LocalStore) Open(key string) (io.ReadSeekCloser, error) {
+	name, err := ls.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrBlobNotFound
+		}
+		return nil, fmt.Errorf("failed to open blob %s: %v", key, err)
+	}
+	return f, nil
+}
+
+func (ls *LocalStore) Delete(key string) error {
+	name, err := ls.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete blob %s: %v", key, err)
+	}
+	return nil
+}
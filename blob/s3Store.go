@@ -0,0 +1,248 @@
+package blob
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// unsignedPayload is sent instead of the SHA-256 of the body, so that
+// uploads can be streamed without reading them twice.
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// S3Store keeps blobs as objects in a bucket of an S3-compatible service,
+// such as MinIO. Objects are addressed path-style, as
+// {endpoint}/{bucket}/{key}, and requests are signed with AWS Signature
+// Version 4.
+type S3Store struct {
+	endpoint  string
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+func NewS3Store(endpoint, bucket, region, accessKey, secretKey string) *S3Store {
+	return &S3Store{
+		endpoint:  strings.TrimSuffix(endpoint, "/"),
+		bucket:    bucket,
+		region:    region,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		client:    http.DefaultClient,
+	}
+}
+
+func (s *S3Store) Put(key string, r io.Reader, size int64) error {
+	resp, err := s.do("PUT", key, r, size, nil)
+	if err != nil {
+		return fmt.Errorf("failed to put blob %s: %v", key, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to put blob %s: %v", key, s3Error(resp))
+	}
+	return nil
+}
+
+// Open looks the object up and returns a reader that fetches its contents
+// lazily, with a ranged GET from the current offset, so that seeking before
+// the first read costs nothing.
+func (s *S3Store) Open(key string) (io.ReadSeekCloser, error) {
+	resp, err := s.do("HEAD", key, nil, 0, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open blob %s: %v", key, err)
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return &s3Object{store: s, key: key, size: resp.ContentLength}, nil
+	case http.StatusNotFound:
+		return nil, ErrBlobNotFound
+	default:
+		return nil, fmt.Errorf("failed to open blob %s: %v", key, s3Error(resp))
+	}
+}
+
+func (s *S3Store) Delete(key string) error {
+	resp, err := s.do("DELETE", key, nil, 0, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete blob %s: %v", key, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK &&
+		resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to delete blob %s: %v", key, s3Error(resp))
+	}
+	return nil
+}
+
+func (s *S3Store) do(method, key string, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	url := s.endpoint + "/" + s3Escape(s.bucket) + "/" + s3Escape(key)
+	if body != nil && size == 0 {
+		body = http.NoBody
+	}
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.ContentLength = size
+
+	s.sign(req, time.Now())
+	return s.client.Do(req)
+}
+
+// sign adds the Signature Version 4 headers to req. The host, the x-amz-*
+// headers and Range, when present, are signed.
+func (s *S3Store) sign(req *http.Request, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	scope := amzDate[:8] + "/" + s.region + "/s3/aws4_request"
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if req.Header.Get("X-Amz-Content-Sha256") == "" {
+		req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") || name == "range" {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		req.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := []byte("AWS4" + s.secretKey)
+	for _, part := range []string{amzDate[:8], s.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape percent-encodes every byte of a key except unreserved characters
+// and "/", as the canonical request of Signature Version 4 expects.
+func s3Escape(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '.' || c == '_' || c == '~' || c == '/' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func s3Error(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if len(body) == 0 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
+
+// s3Object reads an object from the offset it was last seeked to.
+type s3Object struct {
+	store  *S3Store
+	key    string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+func (o *s3Object) Read(p []byte) (int, error) {
+	if o.offset >= o.size {
+		return 0, io.EOF
+	}
+
+	if o.body == nil {
+		header := http.Header{"Range": {fmt.Sprintf("bytes=%d-", o.offset)}}
+		resp, err := o.store.do("GET", o.key, nil, 0, header)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read blob %s: %v", o.key, err)
+		}
+		if resp.StatusCode != http.StatusPartialContent && (resp.StatusCode != http.StatusOK || o.offset != 0) {
+			defer resp.Body.Close()
+			return 0, fmt.Errorf("failed to read blob %s: %v", o.key, s3Error(resp))
+		}
+		o.body = resp.Body
+	}
+
+	n, err := o.body.Read(p)
+	o.offset += int64(n)
+	return n, err
+}
+
+func (o *s3Object) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += o.offset
+	case io.SeekEnd:
+		offset += o.size
+	}
+	if offset < 0 {
+		return 0, errors.New("seek before the start of the blob")
+	}
+
+	if offset != o.offset && o.body != nil {
+		o.body.Close()
+		o.body = nil
+	}
+	o.offset = offset
+	return offset, nil
+}
+
+func (o *s3Object) Close() error {
+	if o.body == nil {
+		return nil
+	}
+	err := o.body.Close()
+	o.body = nil
+	return err
+}
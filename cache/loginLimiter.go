@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type LoginLimiter interface {
+	// Check returns how long the caller has to wait before the next
+	// attempt for login from ip is allowed; zero means go ahead.
+	Check(login, ip string) (time.Duration, error)
+	RegisterFailure(login, ip string) error
+	Reset(login string) error
+}
+
+type LimiterPolicy struct {
+	// Failures are counted within Window of the latest one.
+	Window time.Duration
+	// From DelayAfter failures on, every further attempt is delayed by
+	// BaseDelay, doubling with each failure up to MaxDelay.
+	DelayAfter int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+	// LockoutAfter failures lock the subject out for LockoutDuration.
+	LockoutAfter    int
+	LockoutDuration time.Duration
+}
+
+var (
+	DefaultLoginPolicy = LimiterPolicy{
+		Window:          15 * time.Minute,
+		DelayAfter:      3,
+		BaseDelay:       time.Second,
+		MaxDelay:        30 * time.Second,
+		LockoutAfter:    10,
+		LockoutDuration: 15 * time.Minute,
+	}
+	DefaultIPPolicy = LimiterPolicy{
+		Window:          15 * time.Minute,
+		DelayAfter:      20,
+		BaseDelay:       time.Second,
+		MaxDelay:        30 * time.Second,
+		LockoutAfter:    100,
+		LockoutDuration: 30 * time.Minute,
+	}
+)
+
+func (p LimiterPolicy) blockFor(failures int64) time.Duration {
+	if p.LockoutAfter > 0 && failures >= int64(p.LockoutAfter) {
+		return p.LockoutDuration
+	}
+	if p.DelayAfter <= 0 || failures < int64(p.DelayAfter) {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := int64(p.DelayAfter); i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// RedisLoginLimiter counts failed logins per login and per client IP in
+// Redis, sharing the connection of RedisCache.
+type RedisLoginLimiter struct {
+	rc          *RedisCache
+	loginPolicy LimiterPolicy
+	ipPolicy    LimiterPolicy
+}
+
+func NewRedisLoginLimiter(rc *RedisCache, loginPolicy, ipPolicy LimiterPolicy) *RedisLoginLimiter {
+	return &RedisLoginLimiter{rc: rc, loginPolicy: loginPolicy, ipPolicy: ipPolicy}
+}
+
+func failuresKey(kind, subject string) string {
+	return fmt.Sprintf("login:failures:%s:%s", kind, subject)
+}
+
+func blockedKey(kind, subject string) string {
+	return fmt.Sprintf("login:blocked:%s:%s", kind, subject)
+}
+
+func (rl *RedisLoginLimiter) Check(login, ip string) (time.Duration, error) {
+	var wait time.Duration
+
+	for _, key := range []string{blockedKey("login", login), blockedKey("ip", ip)} {
+		ttl, err := rl.rc.cache.PTTL(rl.rc.ctx, key).Result()
+		if err != nil && err != redis.Nil {
+			return 0, fmt.Errorf("failed to check login attempts in cache: %v", err)
+		}
+		if ttl > wait {
+			wait = ttl
+		}
+	}
+
+	return wait, nil
+}
+
+func (rl *RedisLoginLimiter) RegisterFailure(login, ip string) error {
+	if err := rl.registerFailure("login", login, rl.loginPolicy); err != nil {
+		return err
+	}
+	return rl.registerFailure("ip", ip, rl.ipPolicy)
+}
+
+func (rl *RedisLoginLimiter) registerFailure(kind, subject string, policy LimiterPolicy) error {
+	key := failuresKey(kind, subject)
+
+	pipe := rl.rc.cache.TxPipeline()
+	incr := pipe.Incr(rl.rc.ctx, key)
+	pipe.Expire(rl.rc.ctx, key, policy.Window)
+	if _, err := pipe.Exec(rl.rc.ctx); err != nil {
+		return fmt.Errorf("failed to count failed login of %s %s in cache: %v", kind, subject, err)
+	}
+
+	if block := policy.blockFor(incr.Val()); block > 0 {
+		if err := rl.rc.cache.Set(rl.rc.ctx, blockedKey(kind, subject), 1, block).Err(); err != nil {
+			return fmt.Errorf("failed to block %s %s in cache: %v", kind, subject, err)
+		}
+	}
+
+	return nil
+}
+
+func (rl *RedisLoginLimiter) Reset(login string) error {
+	if err := rl.rc.cache.Del(rl.rc.ctx, failuresKey("login", login), blockedKey("login", login)).Err(); err != nil {
+		return fmt.Errorf("failed to reset failed logins of %s in cache: %v", login, err)
+	}
+	return nil
+}
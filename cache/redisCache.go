@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	bt "restapi/basic_types"
@@ -33,8 +34,12 @@ func NewRedisCache() (*RedisCache, error) {
 	return rc, nil
 }
 
+func taskKey(ownerID, taskID int) string {
+	return fmt.Sprintf("tasks:%d:%d", ownerID, taskID)
+}
+
 func (rc *RedisCache) Set(task *bt.Task) error {
-	id := strconv.Itoa(task.ID)
+	id := taskKey(task.OwnerID, task.ID)
 
 	exists, err := rc.cache.HExists(rc.ctx, id, "name").Result()
 	if err != nil {
@@ -45,8 +50,19 @@ func (rc *RedisCache) Set(task *bt.Task) error {
 	}
 
 	err = rc.cache.HSet(rc.ctx, id, map[string]interface{}{
-		"name":        task.Name,
-		"description": task.Description,
+		"name":         task.Name,
+		"description":  task.Description,
+		"status":       task.Status,
+		"priority":     task.Priority,
+		"project_id":   formatID(task.ProjectID),
+		"parent_id":    formatID(task.ParentID),
+		"tags":         formatTags(task.Tags),
+		"due_at":       formatTime(task.DueAt),
+		"started_at":   formatTime(task.StartedAt),
+		"completed_at": formatTime(task.CompletedAt),
+		"created_at":   task.CreatedAt.Format(time.RFC3339Nano),
+		"updated_at":   task.UpdatedAt.Format(time.RFC3339Nano),
+		"version":      task.Version,
 	}).Err()
 	if err != nil {
 		return fmt.Errorf("failed to insert task %d into cache: %v", task.ID, err)
@@ -60,8 +76,8 @@ func (rc *RedisCache) Set(task *bt.Task) error {
 	return nil
 }
 
-func (rc *RedisCache) Get(taskID int) (*bt.Task, error) {
-	id := strconv.Itoa(taskID)
+func (rc *RedisCache) Get(ownerID, taskID int) (*bt.Task, error) {
+	id := taskKey(ownerID, taskID)
 
 	data, err := rc.cache.HGetAll(rc.ctx, id).Result()
 	if err != nil {
@@ -73,15 +89,119 @@ func (rc *RedisCache) Get(taskID int) (*bt.Task, error) {
 
 	task := &bt.Task{
 		ID:          taskID,
+		OwnerID:     ownerID,
 		Name:        data["name"],
 		Description: data["description"],
+		Status:      data["status"],
+	}
+	if err := decodeTaskFields(task, data); err != nil {
+		return nil, fmt.Errorf("failed to parse task %d from cache: %v", taskID, err)
 	}
 
 	return task, nil
 }
 
-func (rc *RedisCache) Delete(taskID int) error {
-	id := strconv.Itoa(taskID)
+func decodeTaskFields(task *bt.Task, data map[string]string) error {
+	var err error
+
+	if task.Priority, err = strconv.Atoi(data["priority"]); err != nil {
+		return err
+	}
+	if task.Version, err = strconv.Atoi(data["version"]); err != nil {
+		return err
+	}
+	if task.ProjectID, err = parseID(data["project_id"]); err != nil {
+		return err
+	}
+	if task.ParentID, err = parseID(data["parent_id"]); err != nil {
+		return err
+	}
+	if task.Tags, err = parseTags(data["tags"]); err != nil {
+		return err
+	}
+	if task.DueAt, err = parseTime(data["due_at"]); err != nil {
+		return err
+	}
+	if task.StartedAt, err = parseTime(data["started_at"]); err != nil {
+		return err
+	}
+	if task.CompletedAt, err = parseTime(data["completed_at"]); err != nil {
+		return err
+	}
+
+	if task.CreatedAt, err = time.Parse(time.RFC3339Nano, data["created_at"]); err != nil {
+		return err
+	}
+	if task.UpdatedAt, err = time.Parse(time.RFC3339Nano, data["updated_at"]); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// formatTime and parseTime store optional timestamps as hash fields, using
+// an empty string for nil.
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+func parseTime(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// formatID and parseID store an optional reference, using an empty string
+// for nil.
+func formatID(id *int) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.Itoa(*id)
+}
+
+func parseID(s string) (*int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	id, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+// formatTags and parseTags store tags as a JSON array, since a tag may
+// contain any character.
+func formatTags(tags []string) string {
+	if tags == nil {
+		tags = []string{}
+	}
+	data, _ := json.Marshal(tags)
+	return string(data)
+}
+
+func parseTags(s string) ([]string, error) {
+	tags := []string{}
+	if s == "" {
+		return tags, nil
+	}
+	if err := json.Unmarshal([]byte(s), &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func (rc *RedisCache) Delete(ownerID, taskID int) error {
+	id := taskKey(ownerID, taskID)
 
 	removed, err := rc.cache.Del(rc.ctx, id).Result()
 	if err != nil {
@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type RevocationList interface {
+	Revoke(jti string, ttl time.Duration) error
+	RevokeUser(userID int, ttl time.Duration) error
+	IsRevoked(jti string, userID int, issuedAt time.Time) (bool, error)
+}
+
+// MemoryRevocationList keeps revocations in process memory. It is used on
+// its own in tests and as a fallback by RedisRevocationList.
+type MemoryRevocationList struct {
+	mu     sync.Mutex
+	tokens map[string]time.Time
+	users  map[int]revokedUser
+}
+
+type revokedUser struct {
+	before    time.Time
+	expiresAt time.Time
+}
+
+func NewMemoryRevocationList() *MemoryRevocationList {
+	return &MemoryRevocationList{
+		tokens: make(map[string]time.Time),
+		users:  make(map[int]revokedUser),
+	}
+}
+
+func (ml *MemoryRevocationList) Revoke(jti string, ttl time.Duration) error {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+
+	ml.tokens[jti] = time.Now().Add(ttl)
+	return nil
+}
+
+func (ml *MemoryRevocationList) RevokeUser(userID int, ttl time.Duration) error {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+
+	now := time.Now()
+	ml.users[userID] = revokedUser{before: now.Truncate(time.Microsecond), expiresAt: now.Add(ttl)}
+	return nil
+}
+
+func (ml *MemoryRevocationList) IsRevoked(jti string, userID int, issuedAt time.Time) (bool, error) {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+
+	now := time.Now()
+
+	if expiresAt, ok := ml.tokens[jti]; ok {
+		if now.Before(expiresAt) {
+			return true, nil
+		}
+		delete(ml.tokens, jti)
+	}
+
+	if user, ok := ml.users[userID]; ok {
+		if now.Before(user.expiresAt) {
+			return issuedBefore(issuedAt, user.before), nil
+		}
+		delete(ml.users, userID)
+	}
+
+	return false, nil
+}
+
+// RedisRevocationList stores revocations in Redis so that every instance
+// sees them. Revocations are mirrored into memory and consulted when Redis
+// is unreachable.
+type RedisRevocationList struct {
+	rc       *RedisCache
+	fallback *MemoryRevocationList
+}
+
+func NewRedisRevocationList(rc *RedisCache) *RedisRevocationList {
+	return &RedisRevocationList{rc: rc, fallback: NewMemoryRevocationList()}
+}
+
+func revokedTokenKey(jti string) string {
+	return "revoked:token:" + jti
+}
+
+func revokedUserKey(userID int) string {
+	return fmt.Sprintf("revoked:user:%d", userID)
+}
+
+func (rl *RedisRevocationList) Revoke(jti string, ttl time.Duration) error {
+	rl.fallback.Revoke(jti, ttl)
+
+	if err := rl.rc.cache.Set(rl.rc.ctx, revokedTokenKey(jti), 1, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to revoke token %s in cache: %v", jti, err)
+	}
+	return nil
+}
+
+func (rl *RedisRevocationList) RevokeUser(userID int, ttl time.Duration) error {
+	rl.fallback.RevokeUser(userID, ttl)
+
+	before := strconv.FormatInt(time.Now().UnixMicro(), 10)
+	if err := rl.rc.cache.Set(rl.rc.ctx, revokedUserKey(userID), before, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to revoke tokens of user %d in cache: %v", userID, err)
+	}
+	return nil
+}
+
+func (rl *RedisRevocationList) IsRevoked(jti string, userID int, issuedAt time.Time) (bool, error) {
+	revoked, err := rl.isRevoked(jti, userID, issuedAt)
+	if err != nil {
+		fallbackRevoked, _ := rl.fallback.IsRevoked(jti, userID, issuedAt)
+		return fallbackRevoked, err
+	}
+	return revoked, nil
+}
+
+func (rl *RedisRevocationList) isRevoked(jti string, userID int, issuedAt time.Time) (bool, error) {
+	n, err := rl.rc.cache.Exists(rl.rc.ctx, revokedTokenKey(jti)).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check if token %s is revoked: %v", jti, err)
+	}
+	if n > 0 {
+		return true, nil
+	}
+
+	before, err := rl.rc.cache.Get(rl.rc.ctx, revokedUserKey(userID)).Int64()
+	if err != nil {
+		if err == redis.Nil {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check if tokens of user %d are revoked: %v", userID, err)
+	}
+
+	return issuedBefore(issuedAt, time.UnixMicro(before)), nil
+}
+
+// issuedBefore reports whether a token was issued no later than the
+// revocation cutoff. Both are kept at microsecond precision.
+func issuedBefore(issuedAt, before time.Time) bool {
+	return !issuedAt.After(before)
+}
@@ -5,7 +5,7 @@ import (
 )
 
 type TaskCache interface {
-	Get(taskID int) (*bt.Task, error)
+	Get(ownerID, taskID int) (*bt.Task, error)
 	Set(task *bt.Task) error
-	Delete(taskID int) error
+	Delete(ownerID, taskID int) error
 }
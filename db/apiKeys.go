@@ -0,0 +1,118 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type APIKey struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func scanAPIKey(row interface{ Scan(...interface{}) error }, key *APIKey, extra ...interface{}) error {
+	var expiresAt, lastUsedAt sql.NullTime
+	dest := append([]interface{}{&key.ID, &key.UserID, &key.Name, &key.Prefix,
+		pq.Array(&key.Scopes), &expiresAt, &lastUsedAt, &key.CreatedAt}, extra...)
+
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+
+	key.ExpiresAt, key.LastUsedAt = nil, nil
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	return nil
+}
+
+const apiKeyColumns = "id, user_id, name, prefix, scopes, expires_at, last_used_at, created_at"
+
+func (ps *PostgresStore) AddAPIKey(key *APIKey, keyHash string) error {
+	query := `insert into api_keys (user_id, name, prefix, key_hash, scopes, expires_at)
+		values ($1, $2, $3, $4, $5, $6) returning id, created_at`
+
+	err := ps.db.QueryRow(query, key.UserID, key.Name, key.Prefix, keyHash, pq.Array(key.Scopes), key.ExpiresAt).
+		Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert API key of user %d: %v", key.UserID, err)
+	}
+	return nil
+}
+
+func (ps *PostgresStore) GetAllAPIKeys(userID int) ([]APIKey, error) {
+	query := "select " + apiKeyColumns + " from api_keys where user_id = $1 and revoked_at is null order by id"
+
+	rows, err := ps.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select API keys of user %d from DB: %v", userID, err)
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		var key APIKey
+		if err := scanAPIKey(rows, &key); err != nil {
+			return nil, fmt.Errorf("failed to scan API key %d from DB: %v", len(keys)+1, err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func (ps *PostgresStore) RevokeAPIKey(userID, keyID int) error {
+	query := "update api_keys set revoked_at = now() where id = $1 and user_id = $2 and revoked_at is null"
+
+	res, err := ps.db.Exec(query, keyID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key %d: %v", keyID, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+// AuthenticateAPIKey looks up an active key by its hash and returns it
+// together with the current role of its owner.
+func (ps *PostgresStore) AuthenticateAPIKey(keyHash string) (*APIKey, string, error) {
+	var key APIKey
+	var role string
+	query := `update api_keys k set last_used_at = now()
+		from users u
+		where k.key_hash = $1 and k.revoked_at is null and u.id = k.user_id
+		returning k.id, k.user_id, k.name, k.prefix, k.scopes, k.expires_at, k.last_used_at, k.created_at, u.role`
+
+	err := scanAPIKey(ps.db.QueryRow(query, keyHash), &key, &role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, "", ErrAPIKeyNotFound
+		}
+		return nil, "", fmt.Errorf("failed to select API key from DB: %v", err)
+	}
+
+	if key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt) {
+		return nil, "", ErrAPIKeyExpired
+	}
+
+	return &key, role, nil
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	bt "restapi/basic_types"
+)
+
+const attachmentColumns = "a.id, a.owner_id, a.task_id, a.uploader_id, a.filename, a.content_type, a.size, a.blob_key, a.created_at"
+
+// liveAttachmentTask restricts a query on task_attachments a to tasks
+// outside the trash, like liveCommentTask does for comments.
+const liveAttachmentTask = `exists (select 1 from tasks t
+	where t.owner_id = a.owner_id and t.id = a.task_id and t.deleted_at is null)`
+
+func scanAttachment(row interface{ Scan(...interface{}) error }, attachment *bt.Attachment) error {
+	var uploaderID sql.NullInt64
+
+	err := row.Scan(&attachment.ID, &attachment.OwnerID, &attachment.TaskID, &uploaderID, &attachment.Filename,
+		&attachment.ContentType, &attachment.Size, &attachment.BlobKey, &attachment.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	attachment.UploaderID = nil
+	if uploaderID.Valid {
+		id := int(uploaderID.Int64)
+		attachment.UploaderID = &id
+	}
+	return nil
+}
+
+// AddAttachment stores the metadata of a file attached to a task outside
+// the trash and fills in its ID and creation time. The contents must already
+// be in the blob store under BlobKey.
+func (ps *PostgresStore) AddAttachment(attachment *bt.Attachment) error {
+	query := `insert into task_attachments (owner_id, task_id, uploader_id, filename, content_type, size, blob_key)
+		select $1, $2, $3, $4, $5, $6, $7
+		where exists (select 1 from tasks where owner_id = $1 and id = $2 and deleted_at is null)
+		returning id, created_at`
+
+	err := ps.db.QueryRow(query, attachment.OwnerID, attachment.TaskID, attachment.UploaderID, attachment.Filename,
+		attachment.ContentType, attachment.Size, attachment.BlobKey).Scan(&attachment.ID, &attachment.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrTaskNotFound
+		}
+		return fmt.Errorf("failed to insert attachment of task %d: %v", attachment.TaskID, err)
+	}
+	return nil
+}
+
+// GetAttachments returns the attachments of a task, oldest first.
+func (ps *PostgresStore) GetAttachments(ownerID, taskID int) ([]bt.Attachment, error) {
+	if err := taskExists(ps.db, ownerID, taskID); err != nil {
+		return nil, err
+	}
+
+	query := `select ` + attachmentColumns + ` from task_attachments a
+		where a.owner_id = $1 and a.task_id = $2 order by a.id`
+
+	rows, err := ps.db.Query(query, ownerID, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select attachments of task %d: %v", taskID, err)
+	}
+	defer rows.Close()
+
+	attachments := []bt.Attachment{}
+	for rows.Next() {
+		var attachment bt.Attachment
+		if err := scanAttachment(rows, &attachment); err != nil {
+			return nil, fmt.Errorf("failed to scan attachment of task %d: %v", taskID, err)
+		}
+		attachments = append(attachments, attachment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to select attachments of task %d: %v", taskID, err)
+	}
+	return attachments, nil
+}
+
+func (ps *PostgresStore) GetAttachment(ownerID, taskID, attachmentID int) (*bt.Attachment, error) {
+	query := `select ` + attachmentColumns + ` from task_attachments a
+		where a.owner_id = $1 and a.task_id = $2 and a.id = $3 and ` + liveAttachmentTask
+
+	var attachment bt.Attachment
+	if err := scanAttachment(ps.db.QueryRow(query, ownerID, taskID, attachmentID), &attachment); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAttachmentNotFound
+		}
+		return nil, fmt.Errorf("failed to select attachment %d: %v", attachmentID, err)
+	}
+	return &attachment, nil
+}
+
+// DeleteAttachment removes the metadata of an attachment and returns the
+// key of its contents, which the caller removes from the blob store.
+func (ps *PostgresStore) DeleteAttachment(ownerID, taskID, attachmentID int) (string, error) {
+	query := `delete from task_attachments a
+		where a.owner_id = $1 and a.task_id = $2 and a.id = $3 and ` + liveAttachmentTask + `
+		returning a.blob_key`
+
+	var key string
+	if err := ps.db.QueryRow(query, ownerID, taskID, attachmentID).Scan(&key); err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrAttachmentNotFound
+		}
+		return "", fmt.Errorf("failed to delete attachment %d: %v", attachmentID, err)
+	}
+	return key, nil
+}
@@ -0,0 +1,144 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	bt "restapi/basic_types"
+)
+
+const commentColumns = "c.id, c.owner_id, c.task_id, c.author_id, c.body, c.created_at, c.edited_at"
+
+// liveCommentTask restricts a query on task_comments c to tasks outside the
+// trash: comments are hidden while their task is there and removed with it
+// when the trash is purged.
+const liveCommentTask = `exists (select 1 from tasks t
+	where t.owner_id = c.owner_id and t.id = c.task_id and t.deleted_at is null)`
+
+func scanComment(row interface{ Scan(...interface{}) error }, comment *bt.Comment) error {
+	var authorID sql.NullInt64
+	var editedAt sql.NullTime
+
+	err := row.Scan(&comment.ID, &comment.OwnerID, &comment.TaskID, &authorID, &comment.Body,
+		&comment.CreatedAt, &editedAt)
+	if err != nil {
+		return err
+	}
+
+	comment.AuthorID, comment.EditedAt = nil, nil
+	if authorID.Valid {
+		id := int(authorID.Int64)
+		comment.AuthorID = &id
+	}
+	if editedAt.Valid {
+		comment.EditedAt = &editedAt.Time
+	}
+	return nil
+}
+
+// AddComment stores a comment on a task outside the trash and fills in its
+// ID and creation time.
+func (ps *PostgresStore) AddComment(comment *bt.Comment) error {
+	query := `insert into task_comments (owner_id, task_id, author_id, body)
+		select $1, $2, $3, $4
+		where exists (select 1 from tasks where owner_id = $1 and id = $2 and deleted_at is null)
+		returning id, created_at`
+
+	err := ps.db.QueryRow(query, comment.OwnerID, comment.TaskID, comment.AuthorID, comment.Body).
+		Scan(&comment.ID, &comment.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrTaskNotFound
+		}
+		return fmt.Errorf("failed to insert comment on task %d: %v", comment.TaskID, err)
+	}
+	return nil
+}
+
+// GetComments returns up to limit comments of a task with IDs greater than
+// afterID, oldest first, and the afterID of the next page, which is 0 on the
+// last page.
+func (ps *PostgresStore) GetComments(ownerID, taskID, afterID, limit int) ([]bt.Comment, int, error) {
+	if err := taskExists(ps.db, ownerID, taskID); err != nil {
+		return nil, 0, err
+	}
+
+	query := `select ` + commentColumns + ` from task_comments c
+		where c.owner_id = $1 and c.task_id = $2 and c.id > $3
+		order by c.id limit $4`
+
+	rows, err := ps.db.Query(query, ownerID, taskID, afterID, limit+1)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to select comments of task %d: %v", taskID, err)
+	}
+	defer rows.Close()
+
+	comments := []bt.Comment{}
+	for rows.Next() {
+		var comment bt.Comment
+		if err := scanComment(rows, &comment); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan comment of task %d: %v", taskID, err)
+		}
+		comments = append(comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to select comments of task %d: %v", taskID, err)
+	}
+
+	var next int
+	if len(comments) > limit {
+		comments = comments[:limit]
+		next = comments[len(comments)-1].ID
+	}
+	return comments, next, nil
+}
+
+// UpdateComment replaces the body of a comment written by authorID and sets
+// its edit time.
+func (ps *PostgresStore) UpdateComment(ownerID, taskID, commentID, authorID int, body string) (*bt.Comment, error) {
+	query := `update task_comments c set body = $5, edited_at = now()
+		where c.owner_id = $1 and c.task_id = $2 and c.id = $3 and c.author_id = $4 and ` + liveCommentTask + `
+		returning ` + commentColumns
+
+	var comment bt.Comment
+	if err := scanComment(ps.db.QueryRow(query, ownerID, taskID, commentID, authorID, body), &comment); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ps.commentMissReason(ownerID, taskID, commentID)
+		}
+		return nil, fmt.Errorf("failed to update comment %d: %v", commentID, err)
+	}
+	return &comment, nil
+}
+
+func (ps *PostgresStore) DeleteComment(ownerID, taskID, commentID int) error {
+	query := `delete from task_comments c
+		where c.owner_id = $1 and c.task_id = $2 and c.id = $3 and ` + liveCommentTask
+
+	res, err := ps.db.Exec(query, ownerID, taskID, commentID)
+	if err != nil {
+		return fmt.Errorf("failed to delete comment %d: %v", commentID, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete comment %d: %v", commentID, err)
+	}
+	if n == 0 {
+		return ErrCommentNotFound
+	}
+	return nil
+}
+
+// commentMissReason tells why an update of a comment matched no row: the
+// comment is not visible, or it was written by someone else.
+func (ps *PostgresStore) commentMissReason(ownerID, taskID, commentID int) error {
+	query := `select exists (select 1 from task_comments c
+		where c.owner_id = $1 and c.task_id = $2 and c.id = $3 and ` + liveCommentTask + `)`
+
+	var exists bool
+	if err := ps.db.QueryRow(query, ownerID, taskID, commentID).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to select comment %d: %v", commentID, err)
+	}
+	if exists {
+		return ErrNotCommentAuthor
+	}
+	return ErrCommentNotFound
+}
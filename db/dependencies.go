@@ -0,0 +1,252 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	bt "restapi/basic_types"
+
+	"github.com/lib/pq"
+)
+
+// TaskNode is a task in the tree returned by GetTaskTree, with the IDs of
+// the tasks blocking it and its subtasks outside the trash.
+type TaskNode struct {
+	bt.Task
+	BlockedBy []int      `json:"blocked_by"`
+	Children  []TaskNode `json:"children"`
+}
+
+// lockTaskLinks serializes changes to the parent and blocker links of one
+// owner for the rest of the transaction, so that two concurrent links cannot
+// form a cycle that neither of them sees alone, and a task cannot be
+// completed while a blocker is being added to it.
+func lockTaskLinks(tx *sql.Tx, ownerID int) error {
+	if _, err := tx.Exec("select pg_advisory_xact_lock(hashtext('task_links'), $1)", ownerID); err != nil {
+		return fmt.Errorf("failed to lock task links: %v", err)
+	}
+	return nil
+}
+
+// checkUnblocked returns ErrTaskBlocked if a task has a subtask or a blocker
+// outside the trash that is neither done nor cancelled. The caller must hold
+// the link lock, so that the links it reads stay put until the transaction
+// ends.
+func checkUnblocked(tx *sql.Tx, ownerID, taskID int) error {
+	query := `select exists (
+			select 1 from tasks where owner_id = $1 and parent_id = $2
+				and deleted_at is null and status not in ($3, $4)
+		) or exists (
+			select 1 from task_dependencies d
+				join tasks b on b.owner_id = d.owner_id and b.id = d.blocker_id
+			where d.owner_id = $1 and d.task_id = $2 and b.deleted_at is null and b.status not in ($3, $4)
+		)`
+
+	var blocked bool
+	if err := tx.QueryRow(query, ownerID, taskID, bt.StatusDone, bt.StatusCancelled).Scan(&blocked); err != nil {
+		return fmt.Errorf("failed to check blockers of task %d: %v", taskID, err)
+	}
+	if blocked {
+		return ErrTaskBlocked
+	}
+	return nil
+}
+
+// taskExists returns ErrTaskNotFound unless the task exists outside the
+// trash. q is either the store's database or a transaction.
+func taskExists(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}, ownerID, taskID int) error {
+	var exists bool
+	query := "select exists (select 1 from tasks where owner_id = $1 and id = $2 and deleted_at is null)"
+	if err := q.QueryRow(query, ownerID, taskID).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to select task %d from DB: %v", taskID, err)
+	}
+	if !exists {
+		return ErrTaskNotFound
+	}
+	return nil
+}
+
+// SetTaskParent makes a task a subtask of parentID, or a top-level task if
+// parentID is nil. ErrDependencyCycle is returned if the parent is the task
+// itself or one of its subtasks.
+func (ps *PostgresStore) SetTaskParent(ownerID, taskID int, parentID *int, actorID int) (*bt.Task, error) {
+	var task bt.Task
+
+	err := ps.inTx(func(tx *sql.Tx) error {
+		if err := lockTaskLinks(tx, ownerID); err != nil {
+			return err
+		}
+
+		if parentID != nil {
+			if *parentID == taskID {
+				return ErrDependencyCycle
+			}
+			if err := taskExists(tx, ownerID, *parentID); err != nil {
+				return err
+			}
+
+			query := `with recursive ancestors (id, parent_id) as (
+					select id, parent_id from tasks where owner_id = $1 and id = $2
+					union
+					select t.id, t.parent_id from tasks t join ancestors a on t.id = a.parent_id
+					where t.owner_id = $1
+				)
+				select exists (select 1 from ancestors where id = $3)`
+			var cycle bool
+			if err := tx.QueryRow(query, ownerID, *parentID, taskID).Scan(&cycle); err != nil {
+				return fmt.Errorf("failed to check ancestors of task %d: %v", *parentID, err)
+			}
+			if cycle {
+				return ErrDependencyCycle
+			}
+		}
+
+		query := `update tasks set parent_id = $3, updated_at = now(), version = version + 1
+			where owner_id = $1 and id = $2 and deleted_at is null returning ` + taskColumns
+		if err := scanTask(tx.QueryRow(query, ownerID, taskID, parentID), &task); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrTaskNotFound
+			}
+			return fmt.Errorf("failed to update parent of task %d: %v", taskID, err)
+		}
+		return recordRevision(tx, &task, OpUpdate, actorID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// AddTaskBlocker records that taskID cannot be done before blockerID is
+// finished. Adding an existing dependency is not an error; one that would
+// make a task wait for itself fails with ErrDependencyCycle.
+func (ps *PostgresStore) AddTaskBlocker(ownerID, taskID, blockerID int) error {
+	if taskID == blockerID {
+		return ErrDependencyCycle
+	}
+
+	return ps.inTx(func(tx *sql.Tx) error {
+		if err := lockTaskLinks(tx, ownerID); err != nil {
+			return err
+		}
+		for _, id := range []int{taskID, blockerID} {
+			if err := taskExists(tx, ownerID, id); err != nil {
+				return err
+			}
+		}
+
+		query := `with recursive blockers (id) as (
+				select blocker_id from task_dependencies where owner_id = $1 and task_id = $2
+				union
+				select d.blocker_id from task_dependencies d join blockers b on d.task_id = b.id
+				where d.owner_id = $1
+			)
+			select exists (select 1 from blockers where id = $3)`
+		var cycle bool
+		if err := tx.QueryRow(query, ownerID, blockerID, taskID).Scan(&cycle); err != nil {
+			return fmt.Errorf("failed to check blockers of task %d: %v", blockerID, err)
+		}
+		if cycle {
+			return ErrDependencyCycle
+		}
+
+		query = `insert into task_dependencies (owner_id, task_id, blocker_id) values ($1, $2, $3)
+			on conflict do nothing`
+		if _, err := tx.Exec(query, ownerID, taskID, blockerID); err != nil {
+			return fmt.Errorf("failed to add blocker of task %d: %v", taskID, err)
+		}
+		return nil
+	})
+}
+
+func (ps *PostgresStore) RemoveTaskBlocker(ownerID, taskID, blockerID int) error {
+	query := "delete from task_dependencies where owner_id = $1 and task_id = $2 and blocker_id = $3"
+
+	res, err := ps.db.Exec(query, ownerID, taskID, blockerID)
+	if err != nil {
+		return fmt.Errorf("failed to remove blocker of task %d: %v", taskID, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to remove blocker of task %d: %v", taskID, err)
+	}
+	if n == 0 {
+		return ErrDependencyNotFound
+	}
+	return nil
+}
+
+// GetTaskTree returns a task with all of its subtasks outside the trash,
+// each level ordered by ID.
+func (ps *PostgresStore) GetTaskTree(ownerID, taskID int) (*TaskNode, error) {
+	query := `with recursive subtree (id) as (
+			select id from tasks where owner_id = $1 and id = $2 and deleted_at is null
+			union
+			select t.id from tasks t join subtree s on t.parent_id = s.id
+			where t.owner_id = $1 and t.deleted_at is null
+		)
+		select ` + taskColumns + `, array(select d.blocker_id from task_dependencies d
+			where d.owner_id = tasks.owner_id and d.task_id = tasks.id order by d.blocker_id)
+		from tasks where owner_id = $1 and id in (select id from subtree) order by id`
+
+	rows, err := ps.db.Query(query, ownerID, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select subtasks of task %d: %v", taskID, err)
+	}
+	defer rows.Close()
+
+	var nodes []TaskNode
+	for rows.Next() {
+		var node TaskNode
+		var blockedBy pq.Int64Array
+		if err := scanTask(rows, &node.Task, &blockedBy); err != nil {
+			return nil, fmt.Errorf("failed to scan subtask of task %d: %v", taskID, err)
+		}
+		node.BlockedBy = make([]int, len(blockedBy))
+		for i, id := range blockedBy {
+			node.BlockedBy[i] = int(id)
+		}
+		nodes = append(nodes, node)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to select subtasks of task %d: %v", taskID, err)
+	}
+
+	root := BuildTaskTree(nodes, taskID)
+	if root == nil {
+		return nil, ErrTaskNotFound
+	}
+	return root, nil
+}
+
+// BuildTaskTree links nodes into the tree rooted at rootID. Nodes keep their
+// order within each level. It returns nil if the root is missing.
+func BuildTaskTree(nodes []TaskNode, rootID int) *TaskNode {
+	children := make(map[int][]*TaskNode)
+	var root *TaskNode
+	for i := range nodes {
+		node := &nodes[i]
+		if node.ID == rootID {
+			root = node
+		} else if node.ParentID != nil {
+			children[*node.ParentID] = append(children[*node.ParentID], node)
+		}
+	}
+	if root == nil {
+		return nil
+	}
+
+	var build func(node *TaskNode) TaskNode
+	build = func(node *TaskNode) TaskNode {
+		result := *node
+		result.Children = []TaskNode{}
+		for _, child := range children[node.ID] {
+			result.Children = append(result.Children, build(child))
+		}
+		return result
+	}
+
+	tree := build(root)
+	return &tree
+}
@@ -3,8 +3,33 @@ package db
 import "errors"
 
 var (
-	ErrTaskAlreadyExists = errors.New("task already exists")
-	ErrTaskNotFound      = errors.New("task not found")
-	ErrUserNotFound      = errors.New("user not found")
-	ErrIncorrectPassword = errors.New("incorrect password")
+	ErrTaskAlreadyExists    = errors.New("task already exists")
+	ErrTaskNotFound         = errors.New("task not found")
+	ErrInvalidTransition    = errors.New("task status transition not allowed")
+	ErrVersionMismatch      = errors.New("task version mismatch")
+	ErrRevisionNotFound     = errors.New("task revision not found")
+	ErrDependencyCycle      = errors.New("task dependency would create a cycle")
+	ErrDependencyNotFound   = errors.New("task dependency not found")
+	ErrTaskBlocked          = errors.New("task has unfinished blockers or subtasks")
+	ErrInvalidTaskQuery     = errors.New("invalid task query")
+	ErrInvalidTag           = errors.New("invalid tag")
+	ErrProjectNotFound      = errors.New("project not found")
+	ErrCommentNotFound      = errors.New("comment not found")
+	ErrNotCommentAuthor     = errors.New("comment belongs to another user")
+	ErrAttachmentNotFound   = errors.New("attachment not found")
+	ErrUserNotFound         = errors.New("user not found")
+	ErrUserAlreadyExists    = errors.New("user already exists")
+	ErrIncorrectPassword    = errors.New("incorrect password")
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	ErrRefreshTokenExpired  = errors.New("refresh token expired")
+	ErrRefreshTokenReused   = errors.New("refresh token reused")
+	ErrAPIKeyNotFound       = errors.New("API key not found")
+	ErrAPIKeyExpired        = errors.New("API key expired")
+	ErrTOTPNotEnrolled      = errors.New("TOTP is not enrolled")
+	ErrTOTPAlreadyEnabled   = errors.New("TOTP is already enabled")
+	ErrTOTPCodeReused       = errors.New("TOTP code already used")
+	ErrInvalidRecoveryCode  = errors.New("invalid recovery code")
+	ErrInvalidResetToken    = errors.New("invalid or expired password reset token")
+	ErrInvalidLogin         = errors.New("login must be 3-32 characters long and contain only letters, digits, '.', '_' or '-'")
+	ErrWeakPassword         = errors.New("password must be 8-256 characters long and contain at least one letter and one digit")
 )
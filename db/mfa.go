@@ -0,0 +1,147 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+type TOTPState struct {
+	Secret   string
+	Enabled  bool
+	LastStep int64
+}
+
+// SetTOTPSecret stores a secret awaiting confirmation. Until EnableTOTP is
+// called the secret is not required at login.
+func (ps *PostgresStore) SetTOTPSecret(userID int, secret string) error {
+	query := "update users set totp_secret = $1, totp_last_step = null where id = $2 and not totp_enabled"
+
+	res, err := ps.db.Exec(query, secret, userID)
+	if err != nil {
+		return fmt.Errorf("failed to set TOTP secret of user %d: %v", userID, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrTOTPAlreadyEnabled
+	}
+
+	return nil
+}
+
+func (ps *PostgresStore) GetTOTP(userID int) (*TOTPState, error) {
+	var state TOTPState
+	var secret sql.NullString
+	var lastStep sql.NullInt64
+	query := "select totp_secret, totp_enabled, totp_last_step from users where id = $1"
+
+	err := ps.db.QueryRow(query, userID).Scan(&secret, &state.Enabled, &lastStep)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to select TOTP state of user %d: %v", userID, err)
+	}
+	if !secret.Valid {
+		return nil, ErrTOTPNotEnrolled
+	}
+
+	state.Secret = secret.String
+	state.LastStep = lastStep.Int64
+	return &state, nil
+}
+
+// EnableTOTP turns on the second factor and replaces the recovery codes.
+func (ps *PostgresStore) EnableTOTP(userID int, recoveryCodeHashes []string) error {
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := "update users set totp_enabled = true where id = $1 and totp_secret is not null"
+	if _, err := tx.Exec(query, userID); err != nil {
+		return fmt.Errorf("failed to enable TOTP of user %d: %v", userID, err)
+	}
+
+	if _, err := tx.Exec("delete from recovery_codes where user_id = $1", userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes of user %d: %v", userID, err)
+	}
+
+	query = "insert into recovery_codes (user_id, code_hash) values ($1, $2)"
+	for _, hash := range recoveryCodeHashes {
+		if _, err := tx.Exec(query, userID, hash); err != nil {
+			return fmt.Errorf("failed to insert recovery code of user %d: %v", userID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+func (ps *PostgresStore) DisableTOTP(userID int) error {
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := "update users set totp_secret = null, totp_enabled = false, totp_last_step = null where id = $1"
+	if _, err := tx.Exec(query, userID); err != nil {
+		return fmt.Errorf("failed to disable TOTP of user %d: %v", userID, err)
+	}
+
+	if _, err := tx.Exec("delete from recovery_codes where user_id = $1", userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes of user %d: %v", userID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// UseTOTPStep records step as the last accepted one. A step that is not
+// newer than the last accepted one means the code is being replayed.
+func (ps *PostgresStore) UseTOTPStep(userID int, step int64) error {
+	query := "update users set totp_last_step = $1 where id = $2 and (totp_last_step is null or totp_last_step < $1)"
+
+	res, err := ps.db.Exec(query, step, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update TOTP step of user %d: %v", userID, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrTOTPCodeReused
+	}
+
+	return nil
+}
+
+func (ps *PostgresStore) UseRecoveryCode(userID int, codeHash string) error {
+	query := "update recovery_codes set used_at = now() where user_id = $1 and code_hash = $2 and used_at is null"
+
+	res, err := ps.db.Exec(query, userID, codeHash)
+	if err != nil {
+		return fmt.Errorf("failed to use recovery code of user %d: %v", userID, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrInvalidRecoveryCode
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package db
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024
+	argon2Threads = 2
+	argon2KeyLen  = 32
+	argon2SaltLen = 16
+)
+
+var dummyHash, _ = HashPassword("dummy password")
+
+// HashPassword returns an argon2id hash encoded as
+// $argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<key>.
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %v", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// CheckPassword checks password against a stored hash. If the password
+// matches and the hash is a legacy one or uses outdated parameters, upgraded
+// is a new hash from HashPassword to store instead; otherwise it is empty.
+func CheckPassword(password, stored string) (ok bool, upgraded string, err error) {
+	ok, needsRehash, err := verifyPassword(password, stored)
+	if err != nil || !ok || !needsRehash {
+		return ok, "", err
+	}
+
+	upgraded, err = HashPassword(password)
+	if err != nil {
+		return false, "", err
+	}
+	return true, upgraded, nil
+}
+
+// verifyPassword checks password against an encoded hash. Besides argon2id
+// it accepts the legacy unsalted SHA-256 hex digests; needsRehash reports
+// whether the stored hash should be replaced with one from HashPassword.
+// Malformed hashes are reported as errors.
+func verifyPassword(password, encoded string) (ok bool, needsRehash bool, err error) {
+	if !strings.HasPrefix(encoded, "$") {
+		if _, err := hex.DecodeString(encoded); err != nil || len(encoded) != 2*sha256.Size {
+			return false, false, fmt.Errorf("invalid legacy password hash")
+		}
+		h := sha256.Sum256([]byte(password))
+		ok = subtle.ConstantTimeCompare([]byte(hex.EncodeToString(h[:])), []byte(encoded)) == 1
+		return ok, true, nil
+	}
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false, false, fmt.Errorf("unsupported password hash format")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false, fmt.Errorf("unsupported argon2 version %q", parts[2])
+	}
+
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, false, fmt.Errorf("invalid argon2 parameters %q: %v", parts[3], err)
+	}
+	// argon2.IDKey panics on zero time or threads.
+	if time == 0 || threads == 0 || memory < 8*uint32(threads) {
+		return false, false, fmt.Errorf("invalid argon2 parameters %q", parts[3])
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false, fmt.Errorf("invalid argon2 salt: %v", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, false, fmt.Errorf("invalid argon2 key: %v", err)
+	}
+	// An empty key would match every password.
+	if len(salt) == 0 || len(key) < 4 {
+		return false, false, fmt.Errorf("truncated argon2 hash")
+	}
+
+	actual := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+	ok = subtle.ConstantTimeCompare(actual, key) == 1
+	needsRehash = memory != argon2Memory || time != argon2Time || threads != argon2Threads || len(key) != argon2KeyLen
+
+	return ok, needsRehash, nil
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+func (ps *PostgresStore) ChangePassword(userID int, currentPassword, newPassword string) error {
+	var hashFromDb string
+	query := "select hash from users where id = $1"
+
+	err := ps.db.QueryRow(query, userID).Scan(&hashFromDb)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("failed to select user %d from DB: %v", userID, err)
+	}
+
+	ok, _, err := verifyPassword(currentPassword, hashFromDb)
+	if err != nil {
+		return fmt.Errorf("failed to verify password of user %d: %v", userID, err)
+	}
+	if !ok {
+		return ErrIncorrectPassword
+	}
+
+	hash, err := HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	query = "update users set hash = $1 where id = $2 and hash = $3"
+	res, err := ps.db.Exec(query, hash, userID, hashFromDb)
+	if err != nil {
+		return fmt.Errorf("failed to update password of user %d: %v", userID, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		// The password was changed concurrently.
+		return ErrIncorrectPassword
+	}
+
+	return nil
+}
+
+// AddPasswordResetToken stores a reset token for the user with the given
+// login and returns that user.
+func (ps *PostgresStore) AddPasswordResetToken(login, tokenHash string, expiresAt time.Time) (*User, error) {
+	user := User{Login: login}
+	query := `insert into password_reset_tokens (user_id, token_hash, expires_at)
+		select id, $2, $3 from users where login = $1
+		returning user_id`
+
+	err := ps.db.QueryRow(query, login, tokenHash, expiresAt).Scan(&user.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to insert password reset token of user %s: %v", login, err)
+	}
+	return &user, nil
+}
+
+// ResetPassword consumes a reset token and sets the new password. Every
+// other outstanding reset token of the user is invalidated as well.
+func (ps *PostgresStore) ResetPassword(tokenHash, newPassword string) (int, error) {
+	hash, err := HashPassword(newPassword)
+	if err != nil {
+		return -1, err
+	}
+
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return -1, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var userID int
+	query := `update password_reset_tokens set used_at = now()
+		where token_hash = $1 and used_at is null and expires_at > now()
+		returning user_id`
+
+	err = tx.QueryRow(query, tokenHash).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return -1, ErrInvalidResetToken
+		}
+		return -1, fmt.Errorf("failed to use password reset token: %v", err)
+	}
+
+	query = "update password_reset_tokens set used_at = now() where user_id = $1 and used_at is null"
+	if _, err := tx.Exec(query, userID); err != nil {
+		return -1, fmt.Errorf("failed to invalidate password reset tokens of user %d: %v", userID, err)
+	}
+
+	if _, err := tx.Exec("update users set hash = $1 where id = $2", hash, userID); err != nil {
+		return -1, fmt.Errorf("failed to update password of user %d: %v", userID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return -1, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return userID, nil
+}
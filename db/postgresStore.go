@@ -12,6 +12,10 @@ import (
 
 type PostgresStore struct {
 	db *sql.DB
+
+	// searchLanguage is the text search configuration used to index new
+	// tasks and to parse search queries.
+	searchLanguage string
 }
 
 func NewPostgresStore() (*PostgresStore, error) {
@@ -43,5 +47,35 @@ func NewPostgresStore() (*PostgresStore, error) {
 	db.SetMaxIdleConns(5)
 	db.SetConnMaxLifetime(30 * time.Minute)
 
-	return &PostgresStore{db: db}, nil
+	searchLanguage := os.Getenv("TASK_SEARCH_LANGUAGE")
+	if searchLanguage == "" {
+		searchLanguage = defaultSearchLanguage
+	}
+	// Every task insert casts the language to regconfig, so a typo would
+	// otherwise only show up as failing writes.
+	if _, err := db.Exec("select $1::regconfig", searchLanguage); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("invalid TASK_SEARCH_LANGUAGE %q: %v", searchLanguage, err)
+	}
+
+	return &PostgresStore{db: db, searchLanguage: searchLanguage}, nil
+}
+
+// inTx runs fn in a transaction that is committed only if fn succeeds. The
+// error of fn is returned unchanged so callers can inspect it.
+func (ps *PostgresStore) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
 }
@@ -0,0 +1,142 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	bt "restapi/basic_types"
+)
+
+// projectConstraint is the foreign key from tasks to projects.
+const projectConstraint = "tasks_project_fkey"
+
+const projectColumns = "id, owner_id, name, description, created_at, updated_at"
+
+func scanProject(row interface{ Scan(...interface{}) error }, project *bt.Project) error {
+	return row.Scan(&project.ID, &project.OwnerID, &project.Name, &project.Description,
+		&project.CreatedAt, &project.UpdatedAt)
+}
+
+func (ps *PostgresStore) CreateProject(project *bt.Project) error {
+	query := `insert into projects (owner_id, name, description) values ($1, $2, $3)
+		returning id, created_at, updated_at`
+
+	err := ps.db.QueryRow(query, project.OwnerID, project.Name, project.Description).
+		Scan(&project.ID, &project.CreatedAt, &project.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert project: %v", err)
+	}
+	return nil
+}
+
+func (ps *PostgresStore) GetProject(ownerID, projectID int) (*bt.Project, error) {
+	var project bt.Project
+	query := "select " + projectColumns + " from projects where id = $1 and owner_id = $2"
+
+	if err := scanProject(ps.db.QueryRow(query, projectID, ownerID), &project); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrProjectNotFound
+		}
+		return nil, fmt.Errorf("failed to select project %d from DB: %v", projectID, err)
+	}
+	return &project, nil
+}
+
+func (ps *PostgresStore) GetAllProjects(ownerID int) ([]bt.Project, error) {
+	query := "select " + projectColumns + " from projects where owner_id = $1 order by id"
+
+	rows, err := ps.db.Query(query, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select projects from DB: %v", err)
+	}
+	defer rows.Close()
+
+	projects := []bt.Project{}
+	for rows.Next() {
+		var project bt.Project
+		if err := scanProject(rows, &project); err != nil {
+			return nil, fmt.Errorf("failed to scan project from DB: %v", err)
+		}
+		projects = append(projects, project)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to select projects from DB: %v", err)
+	}
+	return projects, nil
+}
+
+func (ps *PostgresStore) UpdateProject(project *bt.Project) (*bt.Project, error) {
+	query := `update projects set name = $1, description = $2, updated_at = now()
+		where id = $3 and owner_id = $4 returning ` + projectColumns
+
+	var updated bt.Project
+	err := scanProject(ps.db.QueryRow(query, project.Name, project.Description, project.ID, project.OwnerID), &updated)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrProjectNotFound
+		}
+		return nil, fmt.Errorf("failed to update project %d: %v", project.ID, err)
+	}
+	return &updated, nil
+}
+
+// DeleteProject removes a project and returns the IDs of the tasks that were
+// in it, including those in the trash. With cascade the tasks are moved to
+// the trash, otherwise they stay where they are; either way they end up in
+// the inbox, so a task restored from the trash later has no project. Every
+// changed task gets a new version and revision.
+func (ps *PostgresStore) DeleteProject(ownerID, projectID int, cascade bool, actorID int) ([]int, error) {
+	var taskIDs []int
+
+	err := ps.inTx(func(tx *sql.Tx) error {
+		var id int
+		query := "select id from projects where id = $1 and owner_id = $2 for update"
+		if err := tx.QueryRow(query, projectID, ownerID).Scan(&id); err != nil {
+			return err
+		}
+
+		query = `update tasks set project_id = null, updated_at = now(), version = version + 1
+			where owner_id = $1 and project_id = $2 returning ` + taskColumns
+		op := OpUpdate
+		if cascade {
+			query = `update tasks set project_id = null, deleted_at = coalesce(deleted_at, now()),
+				updated_at = now(), version = version + 1
+				where owner_id = $1 and project_id = $2 returning ` + taskColumns
+			op = OpDelete
+		}
+
+		rows, err := tx.Query(query, ownerID, projectID)
+		if err != nil {
+			return err
+		}
+		var tasks []bt.Task
+		for rows.Next() {
+			var task bt.Task
+			if err := scanTask(rows, &task); err != nil {
+				rows.Close()
+				return err
+			}
+			tasks = append(tasks, task)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for i := range tasks {
+			if err := recordRevision(tx, &tasks[i], op, actorID); err != nil {
+				return err
+			}
+			taskIDs = append(taskIDs, tasks[i].ID)
+		}
+
+		_, err = tx.Exec("delete from projects where id = $1 and owner_id = $2", projectID, ownerID)
+		return err
+	})
+	if err == sql.ErrNoRows {
+		return nil, ErrProjectNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete project %d: %v", projectID, err)
+	}
+	return taskIDs, nil
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"restapi/blob"
+	"time"
+)
+
+const (
+	defaultTrashRetention = 30 * 24 * time.Hour
+	defaultPurgeInterval  = time.Hour
+)
+
+// Purger periodically removes tasks that have been in the trash for longer
+// than Retention, together with the contents of their attachments in Blobs.
+type Purger struct {
+	Store     TaskStore
+	Blobs     blob.BlobStore
+	Retention time.Duration
+	Interval  time.Duration
+}
+
+// NewPurgerFromEnv configures a Purger from the environment:
+//   - TRASH_RETENTION: how long deleted tasks can be restored (default 720h).
+//   - TRASH_PURGE_INTERVAL: how often the trash is purged (default 1h).
+func NewPurgerFromEnv(store TaskStore, blobs blob.BlobStore) (*Purger, error) {
+	p := &Purger{Store: store, Blobs: blobs, Retention: defaultTrashRetention, Interval: defaultPurgeInterval}
+
+	durations := []struct {
+		env string
+		dst *time.Duration
+	}{
+		{"TRASH_RETENTION", &p.Retention},
+		{"TRASH_PURGE_INTERVAL", &p.Interval},
+	}
+	for _, d := range durations {
+		if v := os.Getenv(d.env); v != "" {
+			parsed, err := time.ParseDuration(v)
+			if err != nil || parsed <= 0 {
+				return nil, fmt.Errorf("invalid %s: %q", d.env, v)
+			}
+			*d.dst = parsed
+		}
+	}
+
+	return p, nil
+}
+
+// PurgeOnce removes every task deleted before now minus the retention period.
+// Attachment contents that cannot be deleted are logged and left behind.
+func (p *Purger) PurgeOnce(now time.Time) (int64, error) {
+	n, keys, err := p.Store.PurgeDeletedTasks(now.Add(-p.Retention))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, key := range keys {
+		if err := p.Blobs.Delete(key); err != nil {
+			log.Printf("Failed to delete attachment of purged task: %v", err)
+		}
+	}
+	return n, nil
+}
+
+// Run purges the trash every Interval until ctx is cancelled.
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := p.PurgeOnce(time.Now())
+		if err != nil {
+			log.Printf("Failed to purge trash: %v", err)
+		} else if n > 0 {
+			log.Printf("Purged %d deleted tasks", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
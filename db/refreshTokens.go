@@ -0,0 +1,98 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+func (ps *PostgresStore) AddRefreshToken(userID int, tokenHash string, expiresAt time.Time) error {
+	query := "insert into refresh_tokens (user_id, token_hash, expires_at) values ($1, $2, $3)"
+
+	if _, err := ps.db.Exec(query, userID, tokenHash, expiresAt); err != nil {
+		return fmt.Errorf("failed to insert refresh token of user %d: %v", userID, err)
+	}
+	return nil
+}
+
+// RotateRefreshToken marks the token identified by oldHash as used and
+// stores newHash in the same family. Presenting an already used or revoked
+// token revokes the whole family and returns ErrRefreshTokenReused.
+func (ps *PostgresStore) RotateRefreshToken(oldHash, newHash string, expiresAt time.Time) (int, error) {
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return -1, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		id           int
+		userID       int
+		familyID     string
+		oldExpiresAt time.Time
+		usedAt       sql.NullTime
+		revokedAt    sql.NullTime
+	)
+	query := `select id, user_id, family_id, expires_at, used_at, revoked_at
+		from refresh_tokens where token_hash = $1 for update`
+
+	err = tx.QueryRow(query, oldHash).Scan(&id, &userID, &familyID, &oldExpiresAt, &usedAt, &revokedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return -1, ErrRefreshTokenNotFound
+		}
+		return -1, fmt.Errorf("failed to select refresh token from DB: %v", err)
+	}
+
+	if usedAt.Valid || revokedAt.Valid {
+		query = "update refresh_tokens set revoked_at = now() where family_id = $1 and revoked_at is null"
+		if _, err := tx.Exec(query, familyID); err != nil {
+			return -1, fmt.Errorf("failed to revoke refresh token family of user %d: %v", userID, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return -1, fmt.Errorf("failed to commit transaction: %v", err)
+		}
+		return -1, ErrRefreshTokenReused
+	}
+
+	if time.Now().After(oldExpiresAt) {
+		return -1, ErrRefreshTokenExpired
+	}
+
+	query = "update refresh_tokens set used_at = now() where id = $1"
+	if _, err := tx.Exec(query, id); err != nil {
+		return -1, fmt.Errorf("failed to mark refresh token of user %d as used: %v", userID, err)
+	}
+
+	query = "insert into refresh_tokens (user_id, family_id, token_hash, expires_at) values ($1, $2, $3, $4)"
+	if _, err := tx.Exec(query, userID, familyID, newHash, expiresAt); err != nil {
+		return -1, fmt.Errorf("failed to insert refresh token of user %d: %v", userID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return -1, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return userID, nil
+}
+
+// RevokeRefreshToken revokes the whole family of the token identified by
+// tokenHash.
+func (ps *PostgresStore) RevokeRefreshToken(tokenHash string) error {
+	query := `update refresh_tokens set revoked_at = now()
+		where revoked_at is null and family_id = (select family_id from refresh_tokens where token_hash = $1)`
+
+	if _, err := ps.db.Exec(query, tokenHash); err != nil {
+		return fmt.Errorf("failed to revoke refresh token: %v", err)
+	}
+	return nil
+}
+
+func (ps *PostgresStore) RevokeUserRefreshTokens(userID int) error {
+	query := "update refresh_tokens set revoked_at = now() where user_id = $1 and revoked_at is null"
+
+	if _, err := ps.db.Exec(query, userID); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens of user %d: %v", userID, err)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	bt "restapi/basic_types"
+	"time"
+)
+
+// Operations recorded in task_revisions.
+const (
+	OpCreate  = "create"
+	OpUpdate  = "update"
+	OpDelete  = "delete"
+	OpRestore = "restore"
+	OpRevert  = "revert"
+)
+
+// TaskRevision is a snapshot of a task taken right after a write. Revision
+// equals the task version the write produced. ActorID is nil if the user who
+// made the change no longer exists.
+type TaskRevision struct {
+	Revision  int       `json:"revision"`
+	Operation string    `json:"operation"`
+	ActorID   *int      `json:"actor_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Task      bt.Task   `json:"task"`
+}
+
+func recordRevision(tx *sql.Tx, task *bt.Task, op string, actorID int) error {
+	snapshot, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("failed to encode revision of task %d: %v", task.ID, err)
+	}
+
+	query := `insert into task_revisions (owner_id, task_id, revision, operation, actor_id, snapshot)
+		values ($1, $2, $3, $4, $5, $6)`
+	if _, err := tx.Exec(query, task.OwnerID, task.ID, task.Version, op, actorID, snapshot); err != nil {
+		return fmt.Errorf("failed to record revision of task %d: %v", task.ID, err)
+	}
+	return nil
+}
+
+const revisionColumns = "revision, operation, actor_id, created_at, snapshot"
+
+func scanRevision(row interface{ Scan(...interface{}) error }, ownerID int, rev *TaskRevision) error {
+	var actorID sql.NullInt64
+	var snapshot []byte
+
+	if err := row.Scan(&rev.Revision, &rev.Operation, &actorID, &rev.CreatedAt, &snapshot); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(snapshot, &rev.Task); err != nil {
+		return fmt.Errorf("failed to decode revision %d: %v", rev.Revision, err)
+	}
+	rev.Task.OwnerID = ownerID
+
+	rev.ActorID = nil
+	if actorID.Valid {
+		id := int(actorID.Int64)
+		rev.ActorID = &id
+	}
+	return nil
+}
+
+// GetTaskRevisions returns the history of a task, oldest first. It is also
+// available while the task is in the trash.
+func (ps *PostgresStore) GetTaskRevisions(ownerID, taskID int) ([]TaskRevision, error) {
+	query := "select " + revisionColumns + " from task_revisions where owner_id = $1 and task_id = $2 order by revision"
+
+	rows, err := ps.db.Query(query, ownerID, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select revisions of task %d: %v", taskID, err)
+	}
+	defer rows.Close()
+
+	var revisions []TaskRevision
+	for rows.Next() {
+		var rev TaskRevision
+		if err := scanRevision(rows, ownerID, &rev); err != nil {
+			return nil, fmt.Errorf("failed to scan revision of task %d: %v", taskID, err)
+		}
+		revisions = append(revisions, rev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to select revisions of task %d: %v", taskID, err)
+	}
+
+	if len(revisions) == 0 {
+		return nil, ErrTaskNotFound
+	}
+	return revisions, nil
+}
+
+// GetTaskAsOf returns the task as it was at the given time. ErrTaskNotFound
+// is returned if the task did not exist yet or was in the trash then.
+func (ps *PostgresStore) GetTaskAsOf(ownerID, taskID int, at time.Time) (*bt.Task, error) {
+	query := `select ` + revisionColumns + ` from task_revisions
+		where owner_id = $1 and task_id = $2 and created_at <= $3
+		order by revision desc limit 1`
+
+	var rev TaskRevision
+	if err := scanRevision(ps.db.QueryRow(query, ownerID, taskID, at), ownerID, &rev); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrTaskNotFound
+		}
+		return nil, fmt.Errorf("failed to select revision of task %d: %v", taskID, err)
+	}
+
+	if rev.Operation == OpDelete {
+		return nil, ErrTaskNotFound
+	}
+	return &rev.Task, nil
+}
+
+// RevertTask sets the editable fields of a task back to those of the given
+// revision. The change is a new revision; status transition rules still
+// apply, and ErrProjectNotFound is returned if the task's project at that
+// revision has been deleted since.
+func (ps *PostgresStore) RevertTask(ownerID, taskID, revision, actorID int) (*bt.Task, error) {
+	query := "select " + revisionColumns + " from task_revisions where owner_id = $1 and task_id = $2 and revision = $3"
+
+	var rev TaskRevision
+	if err := scanRevision(ps.db.QueryRow(query, ownerID, taskID, revision), ownerID, &rev); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrRevisionNotFound
+		}
+		return nil, fmt.Errorf("failed to select revision %d of task %d: %v", revision, taskID, err)
+	}
+
+	return ps.patchTask(ownerID, taskID, actorID, OpRevert, func(task *bt.Task) error {
+		task.Name = rev.Task.Name
+		task.Description = rev.Task.Description
+		task.Status = rev.Task.Status
+		task.Priority = rev.Task.Priority
+		task.DueAt = rev.Task.DueAt
+		task.Tags = rev.Task.Tags
+		task.ProjectID = rev.Task.ProjectID
+		return nil
+	})
+}
@@ -0,0 +1,127 @@
+package db
+
+import (
+	"fmt"
+	bt "restapi/basic_types"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const defaultSearchLanguage = "english"
+
+// TaskSearchResult is a task matched by SearchTasks with its relevance and a
+// fragment of the description where the matched terms are wrapped in <b>.
+type TaskSearchResult struct {
+	bt.Task
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+// searchTerms splits a search string into lowercase words. Everything but
+// letters and digits is a separator, so the terms are safe to put into a
+// tsquery.
+func searchTerms(q string) []string {
+	return strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// prefixTSQuery builds a to_tsquery expression that matches documents
+// containing every term as a word prefix.
+func prefixTSQuery(terms []string) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = term + ":*"
+	}
+	return strings.Join(parts, " & ")
+}
+
+func (ps *PostgresStore) SearchTasks(ownerID int, q string, limit int) ([]TaskSearchResult, error) {
+	terms := searchTerms(q)
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("%w: empty search query", ErrInvalidTaskQuery)
+	}
+	if limit <= 0 || limit > MaxTaskPageSize {
+		limit = MaxTaskPageSize
+	}
+
+	query := `select ` + taskColumns + `, ts_rank_cd(search_vector, q) as rank,
+			ts_headline($2::regconfig, coalesce(description, ''), q, 'MaxWords=30, MinWords=10, MaxFragments=2')
+		from tasks, to_tsquery($2::regconfig, $3) q
+		where owner_id = $1 and deleted_at is null and search_vector @@ q
+		order by rank desc, id
+		limit $4`
+
+	rows, err := ps.db.Query(query, ownerID, ps.searchLanguage, prefixTSQuery(terms), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search tasks: %v", err)
+	}
+	defer rows.Close()
+
+	results := []TaskSearchResult{}
+	for rows.Next() {
+		var result TaskSearchResult
+		if err := scanTask(rows, &result.Task, &result.Rank, &result.Snippet); err != nil {
+			return nil, fmt.Errorf("failed to scan search result: %v", err)
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to search tasks: %v", err)
+	}
+
+	return results, nil
+}
+
+// NaiveSearchTasks is an in-memory stand-in for SearchTasks meant for tests
+// and stores without full-text search. Every term must be a prefix of some
+// word of the name or description; name matches rank higher. It does no
+// stemming and uses the whole description as the snippet.
+func NaiveSearchTasks(tasks []bt.Task, q string, limit int) []TaskSearchResult {
+	terms := searchTerms(q)
+	results := []TaskSearchResult{}
+	if len(terms) == 0 {
+		return results
+	}
+
+	for _, task := range tasks {
+		nameWords := searchTerms(task.Name)
+		descriptionWords := searchTerms(task.Description)
+
+		var rank float64
+		for _, term := range terms {
+			nameHits, descriptionHits := countPrefixes(nameWords, term), countPrefixes(descriptionWords, term)
+			if nameHits+descriptionHits == 0 {
+				rank = 0
+				break
+			}
+			rank += float64(nameHits) + 0.4*float64(descriptionHits)
+		}
+		if rank > 0 {
+			results = append(results, TaskSearchResult{Task: task, Rank: rank, Snippet: task.Description})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].ID < results[j].ID
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+func countPrefixes(words []string, prefix string) int {
+	n := 0
+	for _, w := range words {
+		if strings.HasPrefix(w, prefix) {
+			n++
+		}
+	}
+	return n
+}
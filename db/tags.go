@@ -0,0 +1,78 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// TagCount is a tag together with the number of tasks outside the trash
+// that carry it.
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// taskTagsColumn selects the sorted tags of the tasks row of the enclosing
+// query. It is part of taskColumns.
+const taskTagsColumn = `array(select t.name from task_tags tt join tags t on t.id = tt.tag_id
+		where tt.owner_id = tasks.owner_id and tt.task_id = tasks.id order by t.name)`
+
+// setTaskTags replaces the tags of a task and drops tags of the owner that
+// are no longer used by any task.
+func setTaskTags(tx *sql.Tx, ownerID, taskID int, tags []string) error {
+	if _, err := tx.Exec("delete from task_tags where owner_id = $1 and task_id = $2", ownerID, taskID); err != nil {
+		return fmt.Errorf("failed to clear tags of task %d: %v", taskID, err)
+	}
+
+	if len(tags) > 0 {
+		query := `insert into tags (owner_id, name) select $1, unnest($2::text[])
+			on conflict (owner_id, name) do nothing`
+		if _, err := tx.Exec(query, ownerID, pq.Array(tags)); err != nil {
+			return fmt.Errorf("failed to insert tags of task %d: %v", taskID, err)
+		}
+
+		query = `insert into task_tags (owner_id, task_id, tag_id)
+			select $1, $2, id from tags where owner_id = $1 and name = any($3)`
+		if _, err := tx.Exec(query, ownerID, taskID, pq.Array(tags)); err != nil {
+			return fmt.Errorf("failed to tag task %d: %v", taskID, err)
+		}
+	}
+
+	query := `delete from tags where owner_id = $1
+		and not exists (select 1 from task_tags where task_tags.tag_id = tags.id)`
+	if _, err := tx.Exec(query, ownerID); err != nil {
+		return fmt.Errorf("failed to remove unused tags: %v", err)
+	}
+	return nil
+}
+
+// GetTags returns the tags of a user that are used by at least one task
+// outside the trash, sorted by name.
+func (ps *PostgresStore) GetTags(ownerID int) ([]TagCount, error) {
+	query := `select t.name, count(*) from tags t
+		join task_tags tt on tt.tag_id = t.id
+		join tasks on tasks.owner_id = tt.owner_id and tasks.id = tt.task_id
+		where t.owner_id = $1 and tasks.deleted_at is null
+		group by t.name order by t.name`
+
+	rows, err := ps.db.Query(query, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select tags: %v", err)
+	}
+	defer rows.Close()
+
+	tags := []TagCount{}
+	for rows.Next() {
+		var tag TagCount
+		if err := rows.Scan(&tag.Name, &tag.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %v", err)
+		}
+		tags = append(tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to select tags: %v", err)
+	}
+	return tags, nil
+}
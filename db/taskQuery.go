@@ -0,0 +1,234 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	bt "restapi/basic_types"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	DefaultTaskPageSize = 50
+	MaxTaskPageSize     = 100
+)
+
+// taskSortColumns maps the sort fields accepted by GET /tasks to SQL
+// expressions. Nullable timestamps sort as 'infinity' so that keyset
+// comparisons never see NULL.
+var taskSortColumns = map[string]string{
+	"id":           "id",
+	"name":         "name",
+	"created_at":   "created_at",
+	"updated_at":   "updated_at",
+	"due_at":       "coalesce(due_at, 'infinity'::timestamptz)",
+	"started_at":   "coalesce(started_at, 'infinity'::timestamptz)",
+	"completed_at": "coalesce(completed_at, 'infinity'::timestamptz)",
+}
+
+// TaskQuery selects one page of a user's tasks, or of the user's trash if
+// Deleted is set. The zero value of every filter means "no filter". Tasks
+// must carry any of Tags, or all of them if AllTags is set.
+type TaskQuery struct {
+	OwnerID      int
+	ProjectID    int
+	Deleted      bool
+	Limit        int
+	Sort         string
+	Desc         bool
+	NameContains string
+	MinID        int
+	MaxID        int
+	Tags         []string
+	AllTags      bool
+	After        *TaskCursor
+}
+
+// TaskCursor marks the last task of a page. It is handed to clients only in
+// its opaque encoded form.
+type TaskCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v,omitempty"`
+	ID    int    `json:"id"`
+}
+
+func EncodeTaskCursor(c *TaskCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeTaskCursor(s string) (*TaskCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidTaskQuery)
+	}
+
+	var c TaskCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidTaskQuery)
+	}
+	return &c, nil
+}
+
+// sortKey is the sort parameter as the client wrote it, e.g. "-created_at".
+func (q *TaskQuery) sortKey() string {
+	if q.Desc {
+		return "-" + q.Sort
+	}
+	return q.Sort
+}
+
+func (q *TaskQuery) Validate() error {
+	if q.Sort == "" {
+		q.Sort = "id"
+	}
+	if _, ok := taskSortColumns[q.Sort]; !ok {
+		return fmt.Errorf("%w: unknown sort field %q", ErrInvalidTaskQuery, q.Sort)
+	}
+
+	if q.Limit == 0 {
+		q.Limit = DefaultTaskPageSize
+	}
+	if q.Limit < 0 {
+		return fmt.Errorf("%w: limit must be positive", ErrInvalidTaskQuery)
+	}
+	if q.Limit > MaxTaskPageSize {
+		q.Limit = MaxTaskPageSize
+	}
+
+	if q.MinID < 0 || q.MaxID < 0 || (q.MaxID != 0 && q.MinID > q.MaxID) {
+		return fmt.Errorf("%w: invalid ID range", ErrInvalidTaskQuery)
+	}
+
+	tags, err := bt.NormalizeTags(q.Tags)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTaskQuery, err)
+	}
+	q.Tags = tags
+
+	if q.After != nil {
+		if q.After.Sort != q.sortKey() {
+			return fmt.Errorf("%w: cursor was issued for a different sort order", ErrInvalidTaskQuery)
+		}
+		if !q.validCursorValue() {
+			return fmt.Errorf("%w: malformed cursor", ErrInvalidTaskQuery)
+		}
+	}
+	return nil
+}
+
+// validCursorValue reports whether the value of the cursor can be compared
+// with the sort column, as cursorAfter would have written it.
+func (q *TaskQuery) validCursorValue() bool {
+	switch q.Sort {
+	case "id":
+		return true
+	case "name":
+		return !strings.ContainsRune(q.After.Value, 0)
+	case "due_at", "started_at", "completed_at":
+		if q.After.Value == "infinity" {
+			return true
+		}
+	}
+	_, err := time.Parse(time.RFC3339Nano, q.After.Value)
+	return err == nil
+}
+
+// cursorAfter returns the cursor pointing past task in the query's order.
+func (q *TaskQuery) cursorAfter(task *bt.Task) *TaskCursor {
+	c := &TaskCursor{Sort: q.sortKey(), ID: task.ID}
+
+	switch q.Sort {
+	case "name":
+		c.Value = task.Name
+	case "created_at":
+		c.Value = task.CreatedAt.Format(time.RFC3339Nano)
+	case "updated_at":
+		c.Value = task.UpdatedAt.Format(time.RFC3339Nano)
+	case "due_at":
+		c.Value = cursorTime(task.DueAt)
+	case "started_at":
+		c.Value = cursorTime(task.StartedAt)
+	case "completed_at":
+		c.Value = cursorTime(task.CompletedAt)
+	}
+	return c
+}
+
+func cursorTime(t *time.Time) string {
+	if t == nil {
+		return "infinity"
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// build returns the where/order/limit part of the select for q. It fetches
+// one extra row so the caller can tell whether another page exists.
+func (q *TaskQuery) build() (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conds = append(conds, "owner_id = "+arg(q.OwnerID))
+	if q.Deleted {
+		conds = append(conds, "deleted_at is not null")
+	} else {
+		conds = append(conds, "deleted_at is null")
+	}
+	if q.ProjectID != 0 {
+		conds = append(conds, "project_id = "+arg(q.ProjectID))
+	}
+	if q.NameContains != "" {
+		conds = append(conds, "name ilike '%' || "+arg(escapeLike(q.NameContains))+" || '%'")
+	}
+	if q.MinID != 0 {
+		conds = append(conds, "id >= "+arg(q.MinID))
+	}
+	if q.MaxID != 0 {
+		conds = append(conds, "id <= "+arg(q.MaxID))
+	}
+	if len(q.Tags) > 0 {
+		matches := `(select count(*) from task_tags tt join tags t on t.id = tt.tag_id
+			where tt.owner_id = tasks.owner_id and tt.task_id = tasks.id and t.name = any(` + arg(pq.Array(q.Tags)) + `))`
+		if q.AllTags {
+			conds = append(conds, matches+" = "+arg(len(q.Tags)))
+		} else {
+			conds = append(conds, matches+" > 0")
+		}
+	}
+
+	column := taskSortColumns[q.Sort]
+	op, dir := ">", "asc"
+	if q.Desc {
+		op, dir = "<", "desc"
+	}
+
+	if q.After != nil {
+		switch q.Sort {
+		case "id":
+			conds = append(conds, fmt.Sprintf("id %s %s", op, arg(q.After.ID)))
+		case "name":
+			conds = append(conds, fmt.Sprintf("(%s, id) %s (%s::text, %s)", column, op, arg(q.After.Value), arg(q.After.ID)))
+		default:
+			conds = append(conds, fmt.Sprintf("(%s, id) %s (%s::timestamptz, %s)", column, op, arg(q.After.Value), arg(q.After.ID)))
+		}
+	}
+
+	order := fmt.Sprintf("id %s", dir)
+	if q.Sort != "id" {
+		order = fmt.Sprintf("%s %s, id %s", column, dir, dir)
+	}
+
+	clause := fmt.Sprintf(" where %s order by %s limit %s", strings.Join(conds, " and "), order, arg(q.Limit+1))
+	return clause, args
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
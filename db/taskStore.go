@@ -2,13 +2,61 @@ package db
 
 import (
 	bt "restapi/basic_types"
+	"time"
 )
 
 type TaskStore interface {
-	AddTask(task *bt.Task) error
-	GetTask(id int) (*bt.Task, error)
-	GetAllTasks() ([]bt.Task, error)
-	UpdateTask(task *bt.Task) (*bt.Task, error)
-	DeleteTask(id int) error
-	CheckUser(data *UserData) (int, error)
+	CreateTask(task *bt.Task, actorID int) error
+	AddTask(task *bt.Task, actorID int) error
+	GetTask(ownerID, taskID int) (*bt.Task, error)
+	GetAllTasks(query *TaskQuery) ([]bt.Task, string, error)
+	SearchTasks(ownerID int, query string, limit int) ([]TaskSearchResult, error)
+	UpdateTask(task *bt.Task, actorID int) (*bt.Task, error)
+	PatchTask(ownerID, taskID, actorID int, patch func(task *bt.Task) error) (*bt.Task, error)
+	DeleteTask(ownerID, taskID, version, actorID int) error
+	RestoreTask(ownerID, taskID, actorID int) (*bt.Task, error)
+	PurgeDeletedTasks(before time.Time) (int64, []string, error)
+	GetTaskRevisions(ownerID, taskID int) ([]TaskRevision, error)
+	GetTaskAsOf(ownerID, taskID int, at time.Time) (*bt.Task, error)
+	RevertTask(ownerID, taskID, revision, actorID int) (*bt.Task, error)
+	SetTaskParent(ownerID, taskID int, parentID *int, actorID int) (*bt.Task, error)
+	AddTaskBlocker(ownerID, taskID, blockerID int) error
+	RemoveTaskBlocker(ownerID, taskID, blockerID int) error
+	GetTaskTree(ownerID, taskID int) (*TaskNode, error)
+	GetTags(ownerID int) ([]TagCount, error)
+	AddComment(comment *bt.Comment) error
+	GetComments(ownerID, taskID, afterID, limit int) ([]bt.Comment, int, error)
+	UpdateComment(ownerID, taskID, commentID, authorID int, body string) (*bt.Comment, error)
+	DeleteComment(ownerID, taskID, commentID int) error
+	AddAttachment(attachment *bt.Attachment) error
+	GetAttachments(ownerID, taskID int) ([]bt.Attachment, error)
+	GetAttachment(ownerID, taskID, attachmentID int) (*bt.Attachment, error)
+	DeleteAttachment(ownerID, taskID, attachmentID int) (string, error)
+	CreateProject(project *bt.Project) error
+	GetProject(ownerID, projectID int) (*bt.Project, error)
+	GetAllProjects(ownerID int) ([]bt.Project, error)
+	UpdateProject(project *bt.Project) (*bt.Project, error)
+	DeleteProject(ownerID, projectID int, cascade bool, actorID int) ([]int, error)
+	CreateUser(data *UserData) (*User, error)
+	CheckUser(data *UserData) (*User, error)
+	GetUser(userID int) (*User, error)
+	GetAllUsers() ([]User, error)
+	SetUserRole(userID int, role string) (*User, error)
+	AddRefreshToken(userID int, tokenHash string, expiresAt time.Time) error
+	RotateRefreshToken(oldHash, newHash string, expiresAt time.Time) (int, error)
+	RevokeRefreshToken(tokenHash string) error
+	RevokeUserRefreshTokens(userID int) error
+	AddAPIKey(key *APIKey, keyHash string) error
+	GetAllAPIKeys(userID int) ([]APIKey, error)
+	RevokeAPIKey(userID, keyID int) error
+	AuthenticateAPIKey(keyHash string) (*APIKey, string, error)
+	SetTOTPSecret(userID int, secret string) error
+	GetTOTP(userID int) (*TOTPState, error)
+	EnableTOTP(userID int, recoveryCodeHashes []string) error
+	DisableTOTP(userID int) error
+	UseTOTPStep(userID int, step int64) error
+	UseRecoveryCode(userID int, codeHash string) error
+	ChangePassword(userID int, currentPassword, newPassword string) error
+	AddPasswordResetToken(login, tokenHash string, expiresAt time.Time) (*User, error)
+	ResetPassword(tokenHash, newPassword string) (int, error)
 }
@@ -4,34 +4,187 @@ import (
 	"database/sql"
 	"fmt"
 	bt "restapi/basic_types"
+	"slices"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
-func (ps *PostgresStore) AddTask(task *bt.Task) error {
-	var exists bool
-	query := "select EXISTS (select 1 from tasks where id = $1)"
-	err := ps.db.QueryRow(query, task.ID).Scan(&exists)
+const taskColumns = `id, owner_id, name, description, status, priority, due_at,
+	started_at, completed_at, created_at, updated_at, deleted_at, version, project_id, parent_id, ` + taskTagsColumn
+
+func scanTask(row interface{ Scan(...interface{}) error }, task *bt.Task, extra ...interface{}) error {
+	var dueAt, startedAt, completedAt, deletedAt sql.NullTime
+	var projectID, parentID sql.NullInt64
+
+	dest := append([]interface{}{&task.ID, &task.OwnerID, &task.Name, &task.Description, &task.Status,
+		&task.Priority, &dueAt, &startedAt, &completedAt, &task.CreatedAt, &task.UpdatedAt, &deletedAt,
+		&task.Version, &projectID, &parentID, pq.Array(&task.Tags)}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+
+	task.DueAt, task.StartedAt, task.CompletedAt, task.DeletedAt = nil, nil, nil, nil
+	task.ProjectID, task.ParentID = nil, nil
+	if dueAt.Valid {
+		task.DueAt = &dueAt.Time
+	}
+	if startedAt.Valid {
+		task.StartedAt = &startedAt.Time
+	}
+	if completedAt.Valid {
+		task.CompletedAt = &completedAt.Time
+	}
+	if deletedAt.Valid {
+		task.DeletedAt = &deletedAt.Time
+	}
+	if projectID.Valid {
+		id := int(projectID.Int64)
+		task.ProjectID = &id
+	}
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		task.ParentID = &id
+	}
+	if task.Tags == nil {
+		task.Tags = []string{}
+	}
+	return nil
+}
+
+// maxIDAttempts bounds how often CreateTask retries when the identity
+// sequence hits an ID the owner already picked through AddTask. AddTask
+// moves the sequence past the IDs it inserts, so this only happens when the
+// two race.
+const maxIDAttempts = 5
+
+// taskIDConstraint is the primary key of tasks, violated when an ID is
+// already taken.
+const taskIDConstraint = "tasks_pkey"
+
+func newTaskStatus(task *bt.Task) {
+	status := task.Status
+	if status == "" {
+		status = bt.StatusTodo
+	}
+	task.ApplyStatus(status, time.Now())
+}
+
+// normalizeTaskTags is bt.NormalizeTags with ErrInvalidTag errors and an
+// empty rather than nil result.
+func normalizeTaskTags(tags []string) ([]string, error) {
+	tags, err := bt.NormalizeTags(tags)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTag, err)
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+	return tags, nil
+}
+
+// insertTaskTags stores the tags of a task that has just been inserted.
+func insertTaskTags(tx *sql.Tx, task *bt.Task) error {
+	if len(task.Tags) == 0 {
+		return nil
+	}
+	return setTaskTags(tx, task.OwnerID, task.ID, task.Tags)
+}
+
+// CreateTask inserts a task with an ID allocated by the database and stores
+// that ID in task.ID. actorID is recorded as the author of the first revision.
+func (ps *PostgresStore) CreateTask(task *bt.Task, actorID int) error {
+	newTaskStatus(task)
+	tags, err := normalizeTaskTags(task.Tags)
 	if err != nil {
-		return fmt.Errorf("failed to check if task %d exists: %v", task.ID, err)
+		return err
+	}
+	task.Tags = tags
+
+	query := `insert into tasks (owner_id, name, description, status, priority, due_at, started_at, completed_at,
+		search_language, project_id) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) returning id, created_at, updated_at, version`
+
+	for attempt := 1; ; attempt++ {
+		err := ps.inTx(func(tx *sql.Tx) error {
+			err := tx.QueryRow(query, task.OwnerID, task.Name, task.Description, task.Status, task.Priority,
+				task.DueAt, task.StartedAt, task.CompletedAt, ps.searchLanguage, task.ProjectID).Scan(&task.ID, &task.CreatedAt, &task.UpdatedAt, &task.Version)
+			if err != nil {
+				return err
+			}
+			if err := insertTaskTags(tx, task); err != nil {
+				return err
+			}
+			return recordRevision(tx, task, OpCreate, actorID)
+		})
+		if err == nil {
+			return nil
+		}
+		if isForeignKeyViolation(err, projectConstraint) {
+			return ErrProjectNotFound
+		}
+		if !isConstraintUniqueViolation(err, taskIDConstraint) || attempt == maxIDAttempts {
+			return fmt.Errorf("failed to insert task: %v", err)
+		}
 	}
-	if exists {
-		return ErrTaskAlreadyExists
+}
+
+// AddTask inserts a task with a client-chosen ID. It is kept for the
+// POST /tasks/{id} compatibility route. The identity sequence is moved past
+// the ID, so that CreateTask does not hand it out again.
+func (ps *PostgresStore) AddTask(task *bt.Task, actorID int) error {
+	newTaskStatus(task)
+	tags, err := normalizeTaskTags(task.Tags)
+	if err != nil {
+		return err
 	}
+	task.Tags = tags
 
-	query = "insert into tasks (id, name, description) values ($1, $2, $3)"
-	_, err = ps.db.Exec(query, task.ID, task.Name, task.Description)
+	query := `insert into tasks (id, owner_id, name, description, status, priority, due_at, started_at, completed_at,
+		search_language, project_id) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		returning created_at, updated_at, version`
+	err = ps.inTx(func(tx *sql.Tx) error {
+		err := tx.QueryRow(query, task.ID, task.OwnerID, task.Name, task.Description, task.Status, task.Priority,
+			task.DueAt, task.StartedAt, task.CompletedAt, ps.searchLanguage, task.ProjectID).Scan(&task.CreatedAt, &task.UpdatedAt, &task.Version)
+		if err != nil {
+			return err
+		}
+		if err := advanceTaskIDs(tx, task.ID); err != nil {
+			return err
+		}
+		if err := insertTaskTags(tx, task); err != nil {
+			return err
+		}
+		return recordRevision(tx, task, OpCreate, actorID)
+	})
 	if err != nil {
+		if isConstraintUniqueViolation(err, taskIDConstraint) {
+			return ErrTaskAlreadyExists
+		}
+		if isForeignKeyViolation(err, projectConstraint) {
+			return ErrProjectNotFound
+		}
 		return fmt.Errorf("failed to insert task %d: %v", task.ID, err)
 	}
 	return nil
 }
 
-func (ps *PostgresStore) GetTask(taskID int) (*bt.Task, error) {
+// advanceTaskIDs moves the identity sequence of tasks to id unless it is
+// already past it. The sequence is shared by all owners and is never moved
+// back.
+func advanceTaskIDs(tx *sql.Tx, id int) error {
+	query := `select setval(seq, $1) from (select pg_get_serial_sequence('tasks', 'id')::regclass as seq) s
+		where $1 > coalesce(pg_sequence_last_value(seq), 0)`
+	if _, err := tx.Exec(query, id); err != nil {
+		return fmt.Errorf("failed to advance task IDs past %d: %v", id, err)
+	}
+	return nil
+}
+
+func (ps *PostgresStore) GetTask(ownerID, taskID int) (*bt.Task, error) {
 	var task bt.Task
-	query := "select id, name, description from tasks where id = $1"
+	query := "select " + taskColumns + " from tasks where id = $1 and owner_id = $2 and deleted_at is null"
 
-	err := ps.db.QueryRow(query, taskID).Scan(&task.ID, &task.Name, &task.Description)
+	err := scanTask(ps.db.QueryRow(query, taskID, ownerID), &task)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrTaskNotFound
@@ -41,63 +194,235 @@ func (ps *PostgresStore) GetTask(taskID int) (*bt.Task, error) {
 	return &task, nil
 }
 
-func (ps *PostgresStore) GetAllTasks() ([]bt.Task, error) {
-	query := "select id, name, description from tasks"
+// GetAllTasks returns one page of tasks matching query together with the
+// cursor of the next page, which is empty on the last page.
+func (ps *PostgresStore) GetAllTasks(query *TaskQuery) ([]bt.Task, string, error) {
+	if err := query.Validate(); err != nil {
+		return nil, "", err
+	}
 
-	rows, err := ps.db.Query(query)
+	clause, args := query.build()
+	rows, err := ps.db.Query("select "+taskColumns+" from tasks"+clause, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to select tasks from DB: %v", err)
+		return nil, "", fmt.Errorf("failed to select tasks from DB: %v", err)
 	}
 	defer rows.Close()
 
-	var tasks []bt.Task
+	tasks := []bt.Task{}
 	for rows.Next() {
 		var task bt.Task
-		if err := rows.Scan(&task.ID, &task.Name, &task.Description); err != nil {
-			return nil, fmt.Errorf("failed to scan task %d from DB: %v", len(tasks)+1, err)
+		if err := scanTask(rows, &task); err != nil {
+			return nil, "", fmt.Errorf("failed to scan task %d from DB: %v", len(tasks)+1, err)
 		}
 		tasks = append(tasks, task)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to select tasks from DB: %v", err)
+	}
 
-	return tasks, nil
+	var next string
+	if len(tasks) > query.Limit {
+		tasks = tasks[:query.Limit]
+		next = EncodeTaskCursor(query.cursorAfter(&tasks[len(tasks)-1]))
+	}
+	return tasks, next, nil
 }
 
-func (ps *PostgresStore) UpdateTask(task *bt.Task) (*bt.Task, error) {
-	var exists bool
-	query := "select EXISTS (select 1 from tasks where id = $1)"
-	err := ps.db.QueryRow(query, task.ID).Scan(&exists)
+// UpdateTask replaces the client-editable fields of a task. An empty
+// status, nil tags and a nil project keep the current ones, so a PUT cannot
+// take a task out of its project; a PATCH setting project_id to null does.
+// A non-zero task.Version must match the stored version, otherwise
+// ErrVersionMismatch is returned.
+func (ps *PostgresStore) UpdateTask(task *bt.Task, actorID int) (*bt.Task, error) {
+	return ps.PatchTask(task.OwnerID, task.ID, actorID, func(current *bt.Task) error {
+		if task.Version != 0 && task.Version != current.Version {
+			return ErrVersionMismatch
+		}
+		current.Name = task.Name
+		current.Description = task.Description
+		current.Priority = task.Priority
+		current.DueAt = task.DueAt
+		if task.Status != "" {
+			current.Status = task.Status
+		}
+		if task.Tags != nil {
+			current.Tags = task.Tags
+		}
+		if task.ProjectID != nil {
+			current.ProjectID = task.ProjectID
+		}
+		return nil
+	})
+}
+
+// ApplyTaskPatch runs patch on a copy of current and returns the result.
+// Server-managed fields, including the parent which only changes through
+// SetTaskParent, are restored afterwards, a status change not allowed by
+// the transitions table fails with ErrInvalidTransition, tags are normalized
+// and the status timestamps are updated. Stores use it inside their own
+// locking so that a patch always sees the latest version of the task.
+func ApplyTaskPatch(current *bt.Task, patch func(task *bt.Task) error, now time.Time) (*bt.Task, error) {
+	next := *current
+	if err := patch(&next); err != nil {
+		return nil, err
+	}
+
+	next.ID, next.OwnerID, next.ParentID = current.ID, current.OwnerID, current.ParentID
+	next.StartedAt, next.CompletedAt = current.StartedAt, current.CompletedAt
+	next.CreatedAt, next.UpdatedAt, next.DeletedAt = current.CreatedAt, current.UpdatedAt, current.DeletedAt
+	next.Version = current.Version + 1
+
+	tags, err := normalizeTaskTags(next.Tags)
+	if err != nil {
+		return nil, err
+	}
+	next.Tags = tags
+
+	if !bt.CanTransition(current.Status, next.Status) {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, current.Status, next.Status)
+	}
+	next.ApplyStatus(next.Status, now)
+
+	return &next, nil
+}
+
+// PatchTask locks the task row, applies patch through ApplyTaskPatch and
+// stores the result in the same transaction, bumping updated_at and version.
+// Errors returned by patch are passed through unchanged.
+func (ps *PostgresStore) PatchTask(ownerID, taskID, actorID int, patch func(task *bt.Task) error) (*bt.Task, error) {
+	return ps.patchTask(ownerID, taskID, actorID, OpUpdate, patch)
+}
+
+func (ps *PostgresStore) patchTask(ownerID, taskID, actorID int, op string, patch func(task *bt.Task) error) (*bt.Task, error) {
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// The link lock goes before the row lock, in the order SetTaskParent and
+	// AddTaskBlocker take them, so that completing a task cannot deadlock
+	// with a change to its links.
+	if err := lockTaskLinks(tx, ownerID); err != nil {
+		return nil, err
+	}
+
+	var current bt.Task
+	query := `select ` + taskColumns + ` from tasks
+		where id = $1 and owner_id = $2 and deleted_at is null for update`
+	if err := scanTask(tx.QueryRow(query, taskID, ownerID), &current); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrTaskNotFound
+		}
+		return nil, fmt.Errorf("failed to select task %d from DB: %v", taskID, err)
+	}
+
+	next, err := ApplyTaskPatch(&current, patch, time.Now())
 	if err != nil {
-		return nil, fmt.Errorf("failed to check if task %d exists: %v", task.ID, err)
+		return nil, err
 	}
-	if !exists {
-		return nil, ErrTaskNotFound
+
+	if next.Status == bt.StatusDone && current.Status != bt.StatusDone {
+		if err := checkUnblocked(tx, ownerID, taskID); err != nil {
+			return nil, err
+		}
 	}
 
-	query = "update tasks set name = $1, description = $2 where id = $3 returning id, name, description"
+	// Tags go first so that the tags column of the update's returning clause
+	// sees them.
+	if !slices.Equal(current.Tags, next.Tags) {
+		if err := setTaskTags(tx, ownerID, taskID, next.Tags); err != nil {
+			return nil, err
+		}
+	}
+
+	query = `update tasks set name = $1, description = $2, status = $3, priority = $4, due_at = $5,
+		started_at = $6, completed_at = $7, project_id = $8, updated_at = now(), version = $9
+		where id = $10 and owner_id = $11 returning ` + taskColumns
 	var updatedTask bt.Task
 
-	err = ps.db.QueryRow(query, task.Name, task.Description, task.ID).Scan(&updatedTask.ID, &updatedTask.Name, &updatedTask.Description)
+	err = scanTask(tx.QueryRow(query, next.Name, next.Description, next.Status, next.Priority,
+		next.DueAt, next.StartedAt, next.CompletedAt, next.ProjectID, next.Version, taskID, ownerID), &updatedTask)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update task %d: %v", task.ID, err)
+		if isForeignKeyViolation(err, projectConstraint) {
+			return nil, ErrProjectNotFound
+		}
+		return nil, fmt.Errorf("failed to update task %d: %v", taskID, err)
+	}
+
+	if err := recordRevision(tx, &updatedTask, op, actorID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
 	}
 	return &updatedTask, nil
 }
 
-func (ps *PostgresStore) DeleteTask(taskID int) error {
-	query := "delete from tasks where id = $1"
+// DeleteTask moves a task to the trash by setting deleted_at; PurgeDeletedTasks
+// removes it for good later. A non-zero version must match the stored one,
+// otherwise ErrVersionMismatch is returned.
+func (ps *PostgresStore) DeleteTask(ownerID, taskID, version, actorID int) error {
+	query := `update tasks set deleted_at = now(), version = version + 1
+		where id = $1 and owner_id = $2 and deleted_at is null and ($3 = 0 or version = $3)
+		returning ` + taskColumns
 
-	res, err := ps.db.Exec(query, taskID)
+	err := ps.inTx(func(tx *sql.Tx) error {
+		var task bt.Task
+		if err := scanTask(tx.QueryRow(query, taskID, ownerID, version), &task); err != nil {
+			return err
+		}
+		return recordRevision(tx, &task, OpDelete, actorID)
+	})
+	if err == sql.ErrNoRows {
+		if version == 0 {
+			return ErrTaskNotFound
+		}
+		if _, err := ps.GetTask(ownerID, taskID); err != nil {
+			return err
+		}
+		return ErrVersionMismatch
+	}
 	if err != nil {
 		return fmt.Errorf("failed to delete task %d from DB: %v", taskID, err)
 	}
 
-	rowsAffected, err := res.RowsAffected()
+	return nil
+}
+
+func (ps *PostgresStore) RestoreTask(ownerID, taskID, actorID int) (*bt.Task, error) {
+	query := `update tasks set deleted_at = null, updated_at = now(), version = version + 1
+		where id = $1 and owner_id = $2 and deleted_at is not null returning ` + taskColumns
+	var task bt.Task
+
+	err := ps.inTx(func(tx *sql.Tx) error {
+		if err := scanTask(tx.QueryRow(query, taskID, ownerID), &task); err != nil {
+			return err
+		}
+		return recordRevision(tx, &task, OpRestore, actorID)
+	})
 	if err != nil {
-		return err
-	}
-	if rowsAffected == 0 {
-		return ErrTaskNotFound
+		if err == sql.ErrNoRows {
+			return nil, ErrTaskNotFound
+		}
+		return nil, fmt.Errorf("failed to restore task %d: %v", taskID, err)
 	}
+	return &task, nil
+}
 
-	return nil
+// PurgeDeletedTasks permanently removes tasks of all users that were moved to
+// the trash before the given time. It returns how many were removed and the
+// blob keys of their attachments, whose metadata is removed with them.
+func (ps *PostgresStore) PurgeDeletedTasks(before time.Time) (int64, []string, error) {
+	query := `with purged as (delete from tasks where deleted_at < $1 returning owner_id, id)
+		select (select count(*) from purged), array(select a.blob_key from task_attachments a
+			join purged p on p.owner_id = a.owner_id and p.id = a.task_id)`
+
+	var n int64
+	var keys []string
+	if err := ps.db.QueryRow(query, before).Scan(&n, pq.Array(&keys)); err != nil {
+		return 0, nil, fmt.Errorf("failed to purge deleted tasks: %v", err)
+	}
+	return n, keys, nil
 }
@@ -1,38 +1,199 @@
 package db
 
 import (
-	"crypto/sha256"
 	"database/sql"
-	"encoding/hex"
+	"errors"
 	"fmt"
+	"log"
+	"regexp"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+)
+
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
 )
 
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
+
+// isConstraintUniqueViolation reports whether err violates the named unique
+// or primary key constraint.
+func isConstraintUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation && pqErr.Constraint == constraint
+}
+
+func isForeignKeyViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation && pqErr.Constraint == constraint
+}
+
+var loginPattern = regexp.MustCompile(`^[a-zA-Z0-9._-]{3,32}$`)
+
+type User struct {
+	ID          int       `json:"id"`
+	Login       string    `json:"login"`
+	Role        string    `json:"role"`
+	TOTPEnabled bool      `json:"totp_enabled"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
 type UserData struct {
 	Login    string `json:"login"`
 	Password string `json:"password"`
 }
 
-func (ps *PostgresStore) CheckUser(data *UserData) (int, error) {
-	var userID int
+func (data *UserData) Validate() error {
+	if !loginPattern.MatchString(data.Login) {
+		return ErrInvalidLogin
+	}
+
+	return ValidatePassword(data.Password)
+}
+
+// Password length bounds, in characters. argon2id takes passwords of any
+// length; the upper bound only keeps hashing requests cheap.
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 256
+)
+
+func ValidatePassword(password string) error {
+	if n := utf8.RuneCountInString(password); n < minPasswordLength || n > maxPasswordLength {
+		return ErrWeakPassword
+	}
+
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return ErrWeakPassword
+	}
+
+	return nil
+}
+
+func (ps *PostgresStore) CreateUser(data *UserData) (*User, error) {
+	hash, err := HashPassword(data.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	var user User
+	query := "insert into users (login, hash) values ($1, $2) returning id, login, role, totp_enabled, created_at"
+
+	err = ps.db.QueryRow(query, data.Login, hash).Scan(&user.ID, &user.Login, &user.Role, &user.TOTPEnabled, &user.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrUserAlreadyExists
+		}
+		return nil, fmt.Errorf("failed to insert user %s into DB: %v", data.Login, err)
+	}
+
+	return &user, nil
+}
+
+func (ps *PostgresStore) CheckUser(data *UserData) (*User, error) {
+	var user User
 	var hashFromDb string
-	query := "select id, hash from users where login = $1"
+	query := "select id, login, role, totp_enabled, created_at, hash from users where login = $1"
+
+	err := ps.db.QueryRow(query, data.Login).Scan(&user.ID, &user.Login, &user.Role, &user.TOTPEnabled, &user.CreatedAt, &hashFromDb)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			// Spend the same time as for an existing user so that response
+			// times don't reveal which logins exist.
+			verifyPassword(data.Password, dummyHash)
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to select user %s from DB: %v", data.Login, err)
+	}
+
+	ok, upgraded, err := CheckPassword(data.Password, hashFromDb)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify password of user %s: %v", data.Login, err)
+	}
+	if !ok {
+		return nil, ErrIncorrectPassword
+	}
+
+	if upgraded != "" {
+		if err := ps.rehashPassword(user.ID, hashFromDb, upgraded); err != nil {
+			log.Printf("Failed to upgrade password hash of user %d: %v", user.ID, err)
+		}
+	}
+
+	return &user, nil
+}
+
+func (ps *PostgresStore) GetUser(userID int) (*User, error) {
+	var user User
+	query := "select id, login, role, totp_enabled, created_at from users where id = $1"
 
-	err := ps.db.QueryRow(query, data.Login).Scan(&userID, &hashFromDb)
+	err := ps.db.QueryRow(query, userID).Scan(&user.ID, &user.Login, &user.Role, &user.TOTPEnabled, &user.CreatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return -1, ErrUserNotFound
+			return nil, ErrUserNotFound
 		}
-		return -1, fmt.Errorf("failed to select user %s from DB: %v", data.Login, err)
+		return nil, fmt.Errorf("failed to select user %d from DB: %v", userID, err)
+	}
+	return &user, nil
+}
+
+func (ps *PostgresStore) GetAllUsers() ([]User, error) {
+	query := "select id, login, role, totp_enabled, created_at from users order by id"
+
+	rows, err := ps.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select users from DB: %v", err)
 	}
+	defer rows.Close()
 
-	if hashFromDb != createHash(data.Password) {
-		return -1, ErrIncorrectPassword
+	users := []User{}
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.ID, &user.Login, &user.Role, &user.TOTPEnabled, &user.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan user %d from DB: %v", len(users)+1, err)
+		}
+		users = append(users, user)
 	}
 
-	return userID, nil
+	return users, nil
 }
 
-func createHash(password string) string {
-	h := sha256.Sum256([]byte(password))
-	return hex.EncodeToString(h[:])
+func (ps *PostgresStore) SetUserRole(userID int, role string) (*User, error) {
+	var user User
+	query := "update users set role = $1 where id = $2 returning id, login, role, totp_enabled, created_at"
+
+	err := ps.db.QueryRow(query, role, userID).Scan(&user.ID, &user.Login, &user.Role, &user.TOTPEnabled, &user.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to update role of user %d: %v", userID, err)
+	}
+	return &user, nil
+}
+
+// rehashPassword replaces the password hash of a user unless it was changed
+// since oldHash was read.
+func (ps *PostgresStore) rehashPassword(userID int, oldHash, newHash string) error {
+	query := "update users set hash = $1 where id = $2 and hash = $3"
+	if _, err := ps.db.Exec(query, newHash, userID, oldHash); err != nil {
+		return fmt.Errorf("failed to update hash of user %d: %v", userID, err)
+	}
+	return nil
 }
@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"restapi/auth"
+	db "restapi/db"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type apiKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type apiKeyResponse struct {
+	db.APIKey
+	Key string `json:"key"`
+}
+
+// interactiveClaims returns the claims of a request authenticated with a
+// JWT. API keys cannot manage credentials or sessions, otherwise a narrowly
+// scoped key could mint a broader one; they also have no session to log out.
+func interactiveClaims(w http.ResponseWriter, r *http.Request) (*auth.Claims, bool) {
+	claims, ok := ClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	if claims.Scopes != nil {
+		http.Error(w, "Forbidden: this endpoint requires a login session, not an API key", http.StatusForbidden)
+		return nil, false
+	}
+	return claims, true
+}
+
+func (h *Handler) CreateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var req apiKeyRequest
+
+	claims, ok := interactiveClaims(w, r)
+	if !ok {
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	if req.Name == "" || len(req.Scopes) == 0 {
+		http.Error(w, "Invalid request body: name and scopes are required", http.StatusBadRequest)
+		return
+	}
+
+	for _, scope := range req.Scopes {
+		if !auth.RoleHasPermission(claims.Role, auth.Permission(scope)) {
+			http.Error(w, fmt.Sprintf("Scope %q is not available for role %s", scope, claims.Role), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		http.Error(w, "Invalid request body: expires_at must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	secret, err := auth.GenerateAPIKey()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to generate API key: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	key := db.APIKey{
+		UserID:    claims.UserID,
+		Name:      req.Name,
+		Prefix:    auth.APIKeyDisplayPrefix(secret),
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+
+	if err := h.DB.AddAPIKey(&key, auth.HashAPIKey(secret)); err != nil {
+		log.Printf("Failed to insert API key into DB: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to insert API key into DB: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(apiKeyResponse{APIKey: key, Key: secret})
+}
+
+func (h *Handler) GetAllAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := interactiveClaims(w, r)
+	if !ok {
+		return
+	}
+
+	keys, err := h.DB.GetAllAPIKeys(claims.UserID)
+	if err != nil {
+		log.Printf("Failed to get API keys from DB: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to get API keys from DB: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(keys)
+}
+
+func (h *Handler) DeleteAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := interactiveClaims(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid API key ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.DB.RevokeAPIKey(claims.UserID, id); err != nil {
+		if errors.Is(err, db.ErrAPIKeyNotFound) {
+			http.Error(w, fmt.Sprintf("API key %d not found", id), http.StatusNotFound)
+		} else {
+			log.Printf("Failed to revoke API key in DB: %v", err)
+			http.Error(w, fmt.Sprintf("Failed to revoke API key in DB: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
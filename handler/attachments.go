@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	bt "restapi/basic_types"
+	"restapi/blob"
+	db "restapi/db"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// maxAttachmentFormMemory is how much of a multipart upload is kept in
+	// memory; the rest is spooled to a temporary file.
+	maxAttachmentFormMemory = 1 << 20
+	// maxAttachmentFormOverhead allows for the multipart boundaries and
+	// headers around the file itself.
+	maxAttachmentFormOverhead = 64 << 10
+	maxFilenameLength         = 255
+)
+
+func attachmentError(w http.ResponseWriter, err error, taskID int, action string) {
+	switch {
+	case errors.Is(err, db.ErrTaskNotFound):
+		http.Error(w, fmt.Sprintf("Task %d not found", taskID), http.StatusNotFound)
+	case errors.Is(err, db.ErrAttachmentNotFound):
+		http.Error(w, "Attachment not found", http.StatusNotFound)
+	default:
+		log.Printf("Failed to %s: %v", action, err)
+		http.Error(w, fmt.Sprintf("Failed to %s: %v", action, err), http.StatusInternalServerError)
+	}
+}
+
+// UploadAttachmentHandler attaches the file sent in the "file" field of a
+// multipart/form-data request to a task. The content type is sniffed from
+// the first bytes of the file; the one declared by the client is ignored.
+func (h *Handler) UploadAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := taskOwnerID(w, r)
+	if !ok {
+		return
+	}
+
+	id, _, ok := taskIDs(w, r, "")
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, bt.MaxAttachmentSize+maxAttachmentFormOverhead)
+	if err := r.ParseMultipartForm(maxAttachmentFormMemory); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("Attachment must be at most %d bytes", bt.MaxAttachmentSize),
+				http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "Invalid request body: file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	if header.Size == 0 {
+		http.Error(w, "Invalid request body: file is empty", http.StatusBadRequest)
+		return
+	}
+	if header.Size > bt.MaxAttachmentSize {
+		http.Error(w, fmt.Sprintf("Attachment must be at most %d bytes", bt.MaxAttachmentSize),
+			http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	filename := strings.TrimSpace(header.Filename)
+	if filename == "" {
+		filename = "attachment"
+	}
+	if utf8.RuneCountInString(filename) > maxFilenameLength {
+		http.Error(w, fmt.Sprintf("Filename must be at most %d characters long", maxFilenameLength),
+			http.StatusUnprocessableEntity)
+		return
+	}
+
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(file, sniff)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		log.Printf("Failed to rewind attachment: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to rewind attachment: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	key, err := blob.NewKey(fmt.Sprintf("%d/%d", userID, id))
+	if err != nil {
+		attachmentError(w, err, id, "store attachment")
+		return
+	}
+	if err := h.Blobs.Put(key, file, header.Size); err != nil {
+		attachmentError(w, err, id, "store attachment")
+		return
+	}
+
+	uploaderID := taskActorID(r)
+	attachment := &bt.Attachment{
+		OwnerID:     userID,
+		TaskID:      id,
+		UploaderID:  &uploaderID,
+		Filename:    filename,
+		ContentType: http.DetectContentType(sniff[:n]),
+		Size:        header.Size,
+		BlobKey:     key,
+	}
+	if err := h.DB.AddAttachment(attachment); err != nil {
+		if err := h.Blobs.Delete(key); err != nil {
+			log.Printf("Failed to delete blob of rejected attachment: %v", err)
+		}
+		attachmentError(w, err, id, "add attachment")
+		return
+	}
+
+	w.Header().Set("Location", path.Join(r.URL.Path, strconv.Itoa(attachment.ID)))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(attachment)
+}
+
+// GetAttachmentsHandler lists the attachments of a task, oldest first.
+func (h *Handler) GetAttachmentsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := taskOwnerID(w, r)
+	if !ok {
+		return
+	}
+
+	id, _, ok := taskIDs(w, r, "")
+	if !ok {
+		return
+	}
+
+	attachments, err := h.DB.GetAttachments(userID, id)
+	if err != nil {
+		attachmentError(w, err, id, "get attachments")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(attachments)
+}
+
+// DownloadAttachmentHandler streams the contents of an attachment. Range and
+// If-Range requests are served by http.ServeContent; the ETag never changes
+// because attachments are immutable.
+func (h *Handler) DownloadAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := taskOwnerID(w, r)
+	if !ok {
+		return
+	}
+
+	id, attachmentID, ok := taskIDs(w, r, "attachmentID")
+	if !ok {
+		return
+	}
+
+	attachment, err := h.DB.GetAttachment(userID, id, attachmentID)
+	if err != nil {
+		attachmentError(w, err, id, "get attachment")
+		return
+	}
+
+	content, err := h.Blobs.Open(attachment.BlobKey)
+	if err != nil {
+		attachmentError(w, err, id, "open attachment")
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("ETag", strconv.Quote(path.Base(attachment.BlobKey)))
+	http.ServeContent(w, r, "", attachment.CreatedAt, content)
+}
+
+// DeleteAttachmentHandler removes an attachment. Its contents are removed
+// from the blob store after the metadata; a failure there only leaves an
+// unreachable blob behind and is logged.
+func (h *Handler) DeleteAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := taskOwnerID(w, r)
+	if !ok {
+		return
+	}
+
+	id, attachmentID, ok := taskIDs(w, r, "attachmentID")
+	if !ok {
+		return
+	}
+
+	key, err := h.DB.DeleteAttachment(userID, id, attachmentID)
+	if err != nil {
+		attachmentError(w, err, id, "delete attachment")
+		return
+	}
+
+	if err := h.Blobs.Delete(key); err != nil {
+		log.Printf("Failed to delete blob of attachment %d: %v", attachmentID, err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,14 +1,50 @@
 package handler
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"restapi/auth"
+	db "restapi/db"
 	"strings"
 )
 
+type contextKey string
+
+const claimsKey contextKey = "claims"
+
+func WithClaims(ctx context.Context, claims *auth.Claims) context.Context {
+	return context.WithValue(ctx, claimsKey, claims)
+}
+
+func ClaimsFromContext(ctx context.Context) (*auth.Claims, bool) {
+	claims, ok := ctx.Value(claimsKey).(*auth.Claims)
+	return claims, ok
+}
+
+func WithUserID(ctx context.Context, userID int) context.Context {
+	return WithClaims(ctx, &auth.Claims{UserID: userID})
+}
+
+func UserIDFromContext(ctx context.Context) (int, bool) {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return 0, false
+	}
+	return claims.UserID, true
+}
+
+// AuthorizationMiddleware accepts either a JWT or an API key, passed as
+// "Authorization: Bearer <token>" or in the X-API-Key header.
 func (h *Handler) AuthorizationMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if apiKey := r.Header.Get("X-API-Key"); apiKey != "" {
+			h.authorizeAPIKey(w, r, next, apiKey)
+			return
+		}
+
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
 			http.Error(w, "Missing token", http.StatusUnauthorized)
@@ -21,9 +57,14 @@ func (h *Handler) AuthorizationMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		_, err := auth.ValidateToken(parts[1])
+		if auth.IsAPIKey(parts[1]) {
+			h.authorizeAPIKey(w, r, next, parts[1])
+			return
+		}
+
+		claims, err := auth.ValidateToken(parts[1])
 		if err != nil {
-			if err == auth.ErrInvalidToken {
+			if errors.Is(err, auth.ErrInvalidToken) {
 				http.Error(w, "Invalid token", http.StatusUnauthorized)
 				return
 			}
@@ -31,6 +72,52 @@ func (h *Handler) AuthorizationMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		revoked, err := h.Revoked.IsRevoked(claims.ID, claims.UserID, claims.IssuedAt.Time)
+		if err != nil {
+			log.Printf("Failed to check token revocation: %v", err)
+		}
+		if revoked {
+			http.Error(w, "Token has been revoked", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithClaims(r.Context(), claims)))
 	})
 }
+
+func (h *Handler) authorizeAPIKey(w http.ResponseWriter, r *http.Request, next http.Handler, apiKey string) {
+	key, role, err := h.DB.AuthenticateAPIKey(auth.HashAPIKey(apiKey))
+	if err != nil {
+		if errors.Is(err, db.ErrAPIKeyNotFound) || errors.Is(err, db.ErrAPIKeyExpired) {
+			http.Error(w, "Invalid API key", http.StatusUnauthorized)
+			return
+		}
+		log.Printf("Failed to authenticate API key: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to authenticate API key: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	claims := &auth.Claims{UserID: key.UserID, Role: role, Scopes: key.Scopes}
+	next.ServeHTTP(w, r.WithContext(WithClaims(r.Context(), claims)))
+}
+
+// RequirePermission rejects requests whose token does not grant perm. It
+// must run after AuthorizationMiddleware.
+func (h *Handler) RequirePermission(perm auth.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := ClaimsFromContext(r.Context())
+			if !ok {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			if !claims.HasPermission(perm) {
+				http.Error(w, fmt.Sprintf("Forbidden: %s permission required", perm), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	bt "restapi/basic_types"
+	db "restapi/db"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type commentRequest struct {
+	Body string `json:"body"`
+}
+
+type commentPage struct {
+	Comments   []bt.Comment `json:"comments"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
+// decodeCommentBody reads and validates the body of a comment.
+func decodeCommentBody(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req commentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return "", false
+	}
+	defer r.Body.Close()
+
+	body := strings.TrimSpace(req.Body)
+	if body == "" {
+		http.Error(w, "Invalid request body: body is required", http.StatusBadRequest)
+		return "", false
+	}
+	if utf8.RuneCountInString(body) > bt.MaxCommentLength {
+		http.Error(w, fmt.Sprintf("Comment must be at most %d characters long", bt.MaxCommentLength),
+			http.StatusUnprocessableEntity)
+		return "", false
+	}
+	return body, true
+}
+
+func commentError(w http.ResponseWriter, err error, taskID int, action string) {
+	switch {
+	case errors.Is(err, db.ErrTaskNotFound):
+		http.Error(w, fmt.Sprintf("Task %d not found", taskID), http.StatusNotFound)
+	case errors.Is(err, db.ErrCommentNotFound):
+		http.Error(w, "Comment not found", http.StatusNotFound)
+	case errors.Is(err, db.ErrNotCommentAuthor):
+		http.Error(w, "Forbidden: "+err.Error(), http.StatusForbidden)
+	default:
+		log.Printf("Failed to %s: %v", action, err)
+		http.Error(w, fmt.Sprintf("Failed to %s: %v", action, err), http.StatusInternalServerError)
+	}
+}
+
+// CreateCommentHandler adds a comment to a task on behalf of the
+// authenticated user.
+func (h *Handler) CreateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := taskOwnerID(w, r)
+	if !ok {
+		return
+	}
+
+	id, _, ok := taskIDs(w, r, "")
+	if !ok {
+		return
+	}
+
+	body, ok := decodeCommentBody(w, r)
+	if !ok {
+		return
+	}
+
+	authorID := taskActorID(r)
+	comment := &bt.Comment{OwnerID: userID, TaskID: id, AuthorID: &authorID, Body: body}
+	if err := h.DB.AddComment(comment); err != nil {
+		commentError(w, err, id, "add comment")
+		return
+	}
+
+	w.Header().Set("Location", path.Join(r.URL.Path, strconv.Itoa(comment.ID)))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(comment)
+}
+
+// GetCommentsHandler returns one page of the comments of a task, oldest
+// first. limit is capped like for GET /tasks; cursor is the ID of the last
+// comment of the previous page, as returned in next_cursor.
+func (h *Handler) GetCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := taskOwnerID(w, r)
+	if !ok {
+		return
+	}
+
+	id, _, ok := taskIDs(w, r, "")
+	if !ok {
+		return
+	}
+
+	params := r.URL.Query()
+	limit := db.DefaultTaskPageSize
+	if v := params.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, db.MaxTaskPageSize)
+	}
+
+	var after int
+	if v := params.Get("cursor"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "Malformed cursor", http.StatusBadRequest)
+			return
+		}
+		after = n
+	}
+
+	comments, next, err := h.DB.GetComments(userID, id, after, limit)
+	if err != nil {
+		commentError(w, err, id, "get comments")
+		return
+	}
+
+	page := commentPage{Comments: comments}
+	if next != 0 {
+		page.NextCursor = strconv.Itoa(next)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(page)
+}
+
+// UpdateCommentHandler replaces the body of a comment. Only its author may
+// edit it.
+func (h *Handler) UpdateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := taskOwnerID(w, r)
+	if !ok {
+		return
+	}
+
+	id, commentID, ok := taskIDs(w, r, "commentID")
+	if !ok {
+		return
+	}
+
+	body, ok := decodeCommentBody(w, r)
+	if !ok {
+		return
+	}
+
+	comment, err := h.DB.UpdateComment(userID, id, commentID, taskActorID(r), body)
+	if err != nil {
+		commentError(w, err, id, "update comment")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(comment)
+}
+
+// DeleteCommentHandler removes a comment. Anyone who may write to the task,
+// that is its owner or an administrator, may delete any of its comments.
+func (h *Handler) DeleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := taskOwnerID(w, r)
+	if !ok {
+		return
+	}
+
+	id, commentID, ok := taskIDs(w, r, "commentID")
+	if !ok {
+		return
+	}
+
+	if err := h.DB.DeleteComment(userID, id, commentID); err != nil {
+		commentError(w, err, id, "delete comment")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	db "restapi/db"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// taskIDs parses the task ID and, if name is not empty, a second task ID
+// from the URL.
+func taskIDs(w http.ResponseWriter, r *http.Request, name string) (int, int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	if name == "" {
+		return id, 0, true
+	}
+
+	other, err := strconv.Atoi(mux.Vars(r)[name])
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return id, other, true
+}
+
+func linkError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case errors.Is(err, db.ErrTaskNotFound):
+		http.Error(w, "Task not found", http.StatusNotFound)
+	case errors.Is(err, db.ErrDependencyNotFound):
+		http.Error(w, "Dependency not found", http.StatusNotFound)
+	case errors.Is(err, db.ErrDependencyCycle):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("Failed to %s: %v", action, err)
+		http.Error(w, fmt.Sprintf("Failed to %s: %v", action, err), http.StatusInternalServerError)
+	}
+}
+
+// SetTaskParentHandler makes a task a subtask of {parentID}.
+func (h *Handler) SetTaskParentHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := taskOwnerID(w, r)
+	if !ok {
+		return
+	}
+
+	id, parentID, ok := taskIDs(w, r, "parentID")
+	if !ok {
+		return
+	}
+
+	h.setTaskParent(w, r, userID, id, &parentID)
+}
+
+// RemoveTaskParentHandler makes a subtask a top-level task again.
+func (h *Handler) RemoveTaskParentHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := taskOwnerID(w, r)
+	if !ok {
+		return
+	}
+
+	id, _, ok := taskIDs(w, r, "")
+	if !ok {
+		return
+	}
+
+	h.setTaskParent(w, r, userID, id, nil)
+}
+
+func (h *Handler) setTaskParent(w http.ResponseWriter, r *http.Request, userID, id int, parentID *int) {
+	task, err := h.DB.SetTaskParent(userID, id, parentID, taskActorID(r))
+	if err != nil {
+		linkError(w, err, "set task parent")
+		return
+	}
+
+	if err = h.Cache.Delete(userID, id); err != nil {
+		log.Printf("Failed to delete from cache: %v", err)
+	}
+
+	w.Header().Set("ETag", taskETag(task))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(task)
+}
+
+// AddTaskBlockerHandler records that a task is blocked by {blockerID}.
+func (h *Handler) AddTaskBlockerHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := taskOwnerID(w, r)
+	if !ok {
+		return
+	}
+
+	id, blockerID, ok := taskIDs(w, r, "blockerID")
+	if !ok {
+		return
+	}
+
+	if err := h.DB.AddTaskBlocker(userID, id, blockerID); err != nil {
+		linkError(w, err, "add task blocker")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) RemoveTaskBlockerHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := taskOwnerID(w, r)
+	if !ok {
+		return
+	}
+
+	id, blockerID, ok := taskIDs(w, r, "blockerID")
+	if !ok {
+		return
+	}
+
+	if err := h.DB.RemoveTaskBlocker(userID, id, blockerID); err != nil {
+		linkError(w, err, "remove task blocker")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetTaskTreeHandler returns a task with its subtasks, nested under
+// "children", and the IDs of the tasks blocking each of them.
+func (h *Handler) GetTaskTreeHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := taskOwnerID(w, r)
+	if !ok {
+		return
+	}
+
+	id, _, ok := taskIDs(w, r, "")
+	if !ok {
+		return
+	}
+
+	tree, err := h.DB.GetTaskTree(userID, id)
+	if err != nil {
+		linkError(w, err, "get task tree")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tree)
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	bt "restapi/basic_types"
+	db "restapi/db"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var errInvalidIfMatch = errors.New("If-Match must be * or a list of ETags")
+
+// taskETag is a strong entity tag derived from the task version.
+func taskETag(task *bt.Task) string {
+	return fmt.Sprintf(`"%d"`, task.Version)
+}
+
+// ifMatchVersions returns the task versions listed in the If-Match header
+// of a write request, or nil if any version will do. If-Match uses strong
+// comparison, so weak tags can never match and are left out; a header
+// listing only weak tags yields an empty, non-nil slice.
+func ifMatchVersions(r *http.Request) ([]int, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+
+	versions := []int{}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || strings.HasPrefix(tag, "W/") {
+			continue
+		}
+
+		version, err := strconv.Atoi(strings.Trim(tag, `"`))
+		if err != nil || version <= 0 || !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) {
+			return nil, errInvalidIfMatch
+		}
+		versions = append(versions, version)
+	}
+	return versions, nil
+}
+
+// matchesVersion reports whether version satisfies the versions returned by
+// ifMatchVersions.
+func matchesVersion(versions []int, version int) bool {
+	return versions == nil || slices.Contains(versions, version)
+}
+
+// ifMatchVersion resolves the If-Match header of a write to a task to the
+// single version the store must find, or 0 if any version will do. When
+// several tags are listed, the current version is looked up and required if
+// it is among them; the store compares it again, so a concurrent write still
+// fails the precondition. -1 never matches.
+func (h *Handler) ifMatchVersion(r *http.Request, ownerID, taskID int) (int, error) {
+	versions, err := ifMatchVersions(r)
+	switch {
+	case err != nil:
+		return 0, err
+	case versions == nil:
+		return 0, nil
+	case len(versions) == 0:
+		return -1, nil
+	case len(versions) == 1:
+		return versions[0], nil
+	}
+
+	task, err := h.DB.GetTask(ownerID, taskID)
+	if errors.Is(err, db.ErrTaskNotFound) {
+		// Leave reporting the missing task to the write itself.
+		return -1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if slices.Contains(versions, task.Version) {
+		return task.Version, nil
+	}
+	return -1, nil
+}
+
+// ifMatchError reports a failure of ifMatchVersion.
+func ifMatchError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errInvalidIfMatch) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("Failed to get task from DB: %v", err)
+	http.Error(w, fmt.Sprintf("Failed to get task from DB: %v", err), http.StatusInternalServerError)
+}
+
+// noneMatch reports whether the If-None-Match header matches etag, using the
+// weak comparison RFC 9110 prescribes for it.
+func noneMatch(r *http.Request, etag string) bool {
+	header := strings.TrimSpace(r.Header.Get("If-None-Match"))
+	if header == "" {
+		return false
+	}
+	if header == "*" {
+		return true
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -5,20 +5,31 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
+	"net"
 	"net/http"
+	"path"
 	"restapi/auth"
 	bt "restapi/basic_types"
+	"restapi/blob"
 	cache "restapi/cache"
 	db "restapi/db"
+	"restapi/notify"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	_ "github.com/lib/pq"
 )
 
 type Handler struct {
-	DB    db.TaskStore
-	Cache cache.TaskCache
+	DB       db.TaskStore
+	Cache    cache.TaskCache
+	Revoked  cache.RevocationList
+	Limiter  cache.LoginLimiter
+	Notifier notify.Notifier
+	Blobs    blob.BlobStore
 }
 
 func NewHandler() (*Handler, error) {
@@ -32,7 +43,24 @@ func NewHandler() (*Handler, error) {
 		return nil, err
 	}
 
-	return &Handler{DB: ps, Cache: rc}, nil
+	notifier, err := notify.NewNotifierFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	blobs, err := blob.NewBlobStoreFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Handler{
+		DB:       ps,
+		Cache:    rc,
+		Revoked:  cache.NewRedisRevocationList(rc),
+		Limiter:  cache.NewRedisLoginLimiter(rc, cache.DefaultLoginPolicy, cache.DefaultIPPolicy),
+		Notifier: notifier,
+		Blobs:    blobs,
+	}, nil
 }
 
 func (h *Handler) LoginHandler(w http.ResponseWriter, r *http.Request) {
@@ -44,40 +72,183 @@ func (h *Handler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 
-	userID, err := h.DB.CheckUser(&userData)
+	ip := clientIP(r)
+
+	wait, err := h.Limiter.Check(userData.Login, ip)
+	if err != nil {
+		log.Printf("Failed to check login attempts: %v", err)
+	}
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		http.Error(w, "Too many failed login attempts, try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	user, err := h.DB.CheckUser(&userData)
 	if err != nil {
-		if errors.Is(err, db.ErrUserNotFound) {
-			http.Error(w, "User not found", http.StatusNotFound)
+		if errors.Is(err, db.ErrUserNotFound) || errors.Is(err, db.ErrIncorrectPassword) {
+			if err := h.Limiter.RegisterFailure(userData.Login, ip); err != nil {
+				log.Printf("Failed to register failed login: %v", err)
+			}
+			http.Error(w, "Invalid login or password", http.StatusUnauthorized)
 			return
 		}
-		if errors.Is(err, db.ErrIncorrectPassword) {
-			http.Error(w, "Incorrect password", http.StatusBadRequest)
+		http.Error(w, fmt.Sprintf("Failed to check user: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.Limiter.Reset(userData.Login); err != nil {
+		log.Printf("Failed to reset failed logins: %v", err)
+	}
+
+	if user.TOTPEnabled {
+		mfaToken, err := auth.GenerateMFAToken(user.ID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to generate MFA token: %v", err), http.StatusInternalServerError)
 			return
 		}
-		http.Error(w, fmt.Sprintf("Failed to check user: %v", err), http.StatusInternalServerError)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"mfa_required": true,
+			"mfa_token":    mfaToken,
+		})
 		return
 	}
 
-	token, err := auth.GenerateToken(userID)
+	h.issueTokens(w, user)
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to generate JWT token: %v", err), http.StatusInternalServerError)
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (h *Handler) RegisterHandler(w http.ResponseWriter, r *http.Request) {
+	var userData db.UserData
+
+	if err := json.NewDecoder(r.Body).Decode(&userData); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
 		return
 	}
+	defer r.Body.Close()
 
-	w.Header().Set("Authorization", "Bearer "+token)
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{
-		"token": token,
-	})
+	if err := userData.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.DB.CreateUser(&userData)
+	if err != nil {
+		if errors.Is(err, db.ErrUserAlreadyExists) {
+			http.Error(w, "User already exists", http.StatusConflict)
+			return
+		}
+		log.Printf("Failed to create user: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to create user: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	h.issueTokens(w, user)
+}
+
+// taskOwnerID returns the user whose tasks the request operates on: the
+// caller, or the {userID} from the path on admin routes.
+func taskOwnerID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	claims, ok := ClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return 0, false
+	}
+
+	v, ok := mux.Vars(r)["userID"]
+	if !ok {
+		return claims.UserID, true
+	}
+
+	if !claims.HasPermission(auth.PermTasksAll) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return 0, false
+	}
+
+	ownerID, err := strconv.Atoi(v)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return ownerID, true
+}
+
+// taskActorID returns the user performing a task write, which differs from
+// the owner when an admin works on another user's tasks. It must be called
+// after taskOwnerID has accepted the request.
+func taskActorID(r *http.Request) int {
+	userID, _ := UserIDFromContext(r.Context())
+	return userID
+}
+
+// taskError is a client error found while validating a task, with the
+// status code it should be reported with.
+type taskError struct {
+	status  int
+	message string
 }
 
+func (e *taskError) Error() string {
+	return e.message
+}
+
+// checkTask validates a task built from a request. Malformed JSON, including
+// due_at values that are not RFC 3339, is rejected by the decoder before
+// this point. created_at and updated_at are managed by the server and
+// cleared if the client sends them, as is parent_id, which is set through
+// the parent endpoints.
+func checkTask(task *bt.Task) error {
+	if task.Name == "" || task.Description == "" {
+		return &taskError{http.StatusBadRequest, "Invalid request body"}
+	}
+
+	if task.Status != "" && !bt.IsValidStatus(task.Status) {
+		return &taskError{http.StatusUnprocessableEntity, fmt.Sprintf("Unknown task status %q", task.Status)}
+	}
+
+	if task.Priority < bt.MinPriority || task.Priority > bt.MaxPriority {
+		return &taskError{http.StatusUnprocessableEntity,
+			fmt.Sprintf("Priority must be between %d and %d", bt.MinPriority, bt.MaxPriority)}
+	}
+
+	tags, err := bt.NormalizeTags(task.Tags)
+	if err != nil {
+		return &taskError{http.StatusUnprocessableEntity, err.Error()}
+	}
+	task.Tags = tags
+
+	task.CreatedAt, task.UpdatedAt = time.Time{}, time.Time{}
+	task.ParentID = nil
+	return nil
+}
+
+func validateTask(w http.ResponseWriter, task *bt.Task) bool {
+	if err := checkTask(task); err != nil {
+		te := err.(*taskError)
+		http.Error(w, te.message, te.status)
+		return false
+	}
+	return true
+}
+
+// CreateTaskHandler serves POST /tasks, where the database allocates the ID,
+// the older POST /tasks/{id}, where the client chooses it, and
+// POST /projects/{projectID}/tasks, which creates the task in that project.
 func (h *Handler) CreateTaskHandler(w http.ResponseWriter, r *http.Request) {
 	var task bt.Task
 
-	id, err := strconv.Atoi(mux.Vars(r)["id"])
-	if err != nil {
-		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+	userID, ok := taskOwnerID(w, r)
+	if !ok {
 		return
 	}
 
@@ -87,42 +258,103 @@ func (h *Handler) CreateTaskHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 
-	task.ID = id
+	task.ID = 0
+	task.OwnerID = userID
+
+	location := r.URL.Path
+	projectStatus := http.StatusUnprocessableEntity
+	if v, ok := mux.Vars(r)["projectID"]; ok {
+		projectID, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+		task.ProjectID = &projectID
+		projectStatus = http.StatusNotFound
+		location = location[:strings.LastIndex(location, "/projects/")] + "/tasks"
+	}
 
-	if task.ID == 0 || task.Name == "" || task.Description == "" {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !validateTask(w, &task) {
 		return
 	}
+	if idVar, ok := mux.Vars(r)["id"]; ok {
+		id, err := strconv.Atoi(idVar)
+		if err != nil || id == 0 {
+			http.Error(w, "Invalid task ID", http.StatusBadRequest)
+			return
+		}
+		task.ID = id
 
-	if err := h.DB.AddTask(&task); err != nil {
-		if errors.Is(err, db.ErrTaskAlreadyExists) {
-			http.Error(w, "Task already exists", http.StatusConflict)
-		} else {
-			log.Printf("Failed to insert task into DB: %v", err)
-			http.Error(w, fmt.Sprintf("Failed to insert task into DB: %v", err), http.StatusInternalServerError)
+		if err := h.DB.AddTask(&task, taskActorID(r)); err != nil {
+			if errors.Is(err, db.ErrTaskAlreadyExists) {
+				http.Error(w, "Task already exists", http.StatusConflict)
+			} else if errors.Is(err, db.ErrProjectNotFound) {
+				http.Error(w, fmt.Sprintf("Project %d not found", *task.ProjectID), projectStatus)
+			} else {
+				log.Printf("Failed to insert task into DB: %v", err)
+				http.Error(w, fmt.Sprintf("Failed to insert task into DB: %v", err), http.StatusInternalServerError)
+			}
+			return
 		}
-		return
+	} else {
+		if err := h.DB.CreateTask(&task, taskActorID(r)); err != nil {
+			if errors.Is(err, db.ErrProjectNotFound) {
+				http.Error(w, fmt.Sprintf("Project %d not found", *task.ProjectID), projectStatus)
+			} else {
+				log.Printf("Failed to insert task into DB: %v", err)
+				http.Error(w, fmt.Sprintf("Failed to insert task into DB: %v", err), http.StatusInternalServerError)
+			}
+			return
+		}
+		location = path.Join(location, strconv.Itoa(task.ID))
 	}
 
+	w.Header().Set("Location", location)
+	w.Header().Set("ETag", taskETag(&task))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(task)
 }
 
 func (h *Handler) GetTaskHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := taskOwnerID(w, r)
+	if !ok {
+		return
+	}
+
 	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
 		http.Error(w, "Invalid task ID", http.StatusBadRequest)
 		return
 	}
 
-	task, err := h.Cache.Get(id)
-	if err != nil {
-		log.Printf("Failed to get from cache: %v", err)
+	var task *bt.Task
+	if v := r.URL.Query().Get("as_of"); v != "" {
+		asOf, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "as_of must be an RFC 3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		// Past versions come from the history and are never cached.
+		task, err = h.DB.GetTaskAsOf(userID, id, asOf)
+		if err != nil {
+			if errors.Is(err, db.ErrTaskNotFound) {
+				http.Error(w, fmt.Sprintf("Task %d not found at %s", id, v), http.StatusNotFound)
+			} else {
+				log.Printf("Failed to get task revision from DB: %v", err)
+				http.Error(w, fmt.Sprintf("Failed to get task revision from DB: %v", err), http.StatusInternalServerError)
+			}
+			return
+		}
+	} else {
+		task, err = h.Cache.Get(userID, id)
+		if err != nil {
+			log.Printf("Failed to get from cache: %v", err)
+		}
 	}
 
 	if task == nil {
-		task, err = h.DB.GetTask(id)
+		task, err = h.DB.GetTask(userID, id)
 		if err != nil {
 			if errors.Is(err, db.ErrTaskNotFound) {
 				http.Error(w, fmt.Sprintf("Task %d not found", id), http.StatusNotFound)
@@ -138,14 +370,96 @@ func (h *Handler) GetTaskHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	etag := taskETag(task)
+	w.Header().Set("ETag", etag)
+	if noneMatch(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(task)
 }
 
+type taskPage struct {
+	Tasks      []bt.Task `json:"tasks"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// parseTaskQuery reads the GET /tasks query string: limit, cursor,
+// sort (a field name, prefixed with "-" for descending order), name,
+// min_id, max_id, any number of tag parameters and tag_match ("any", the
+// default, or "all").
+func parseTaskQuery(r *http.Request, ownerID int) (*db.TaskQuery, error) {
+	params := r.URL.Query()
+	query := &db.TaskQuery{
+		OwnerID:      ownerID,
+		Sort:         strings.TrimPrefix(params.Get("sort"), "-"),
+		Desc:         strings.HasPrefix(params.Get("sort"), "-"),
+		NameContains: params.Get("name"),
+		Tags:         params["tag"],
+	}
+
+	switch params.Get("tag_match") {
+	case "", "any":
+	case "all":
+		query.AllTags = true
+	default:
+		return nil, fmt.Errorf("%w: tag_match must be \"any\" or \"all\"", db.ErrInvalidTaskQuery)
+	}
+
+	ints := map[string]*int{"limit": &query.Limit, "min_id": &query.MinID, "max_id": &query.MaxID}
+	for name, dst := range ints {
+		if v := params.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s must be an integer", db.ErrInvalidTaskQuery, name)
+			}
+			*dst = n
+		}
+	}
+
+	if c := params.Get("cursor"); c != "" {
+		cursor, err := db.DecodeTaskCursor(c)
+		if err != nil {
+			return nil, err
+		}
+		query.After = cursor
+	}
+
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	return query, nil
+}
+
 func (h *Handler) GetAllTasksHandler(w http.ResponseWriter, r *http.Request) {
-	tasks, err := h.DB.GetAllTasks()
+	h.listTasks(w, r, false, 0)
+}
+
+// listTasks writes one page of the owner's tasks, or of the owner's trash if
+// deleted is set. A non-zero projectID limits the page to that project.
+func (h *Handler) listTasks(w http.ResponseWriter, r *http.Request, deleted bool, projectID int) {
+	userID, ok := taskOwnerID(w, r)
+	if !ok {
+		return
+	}
+
+	query, err := parseTaskQuery(r, userID)
 	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query.Deleted = deleted
+	query.ProjectID = projectID
+
+	tasks, next, err := h.DB.GetAllTasks(query)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidTaskQuery) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		log.Printf("Failed to get all tasks from DB: %v", err)
 		http.Error(w, fmt.Sprintf("Failed to get all tasks from DB: %v", err), http.StatusInternalServerError)
 		return
@@ -153,14 +467,19 @@ func (h *Handler) GetAllTasksHandler(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(tasks)
+	json.NewEncoder(w).Encode(taskPage{Tasks: tasks, NextCursor: next})
 }
 
 func (h *Handler) UpdateTaskHandler(w http.ResponseWriter, r *http.Request) {
 	var task bt.Task
 
+	userID, ok := taskOwnerID(w, r)
+	if !ok {
+		return
+	}
+
 	id, err := strconv.Atoi(mux.Vars(r)["id"])
-	if err != nil {
+	if err != nil || id == 0 {
 		http.Error(w, "Invalid task ID", http.StatusBadRequest)
 		return
 	}
@@ -172,16 +491,26 @@ func (h *Handler) UpdateTaskHandler(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
 	task.ID = id
+	task.OwnerID = userID
+	if task.Version, err = h.ifMatchVersion(r, userID, id); err != nil {
+		ifMatchError(w, err)
+		return
+	}
 
-	if task.ID == 0 || task.Name == "" || task.Description == "" {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !validateTask(w, &task) {
 		return
 	}
 
-	updatedTask, err := h.DB.UpdateTask(&task)
+	updatedTask, err := h.DB.UpdateTask(&task, taskActorID(r))
 	if err != nil {
 		if errors.Is(err, db.ErrTaskNotFound) {
 			http.Error(w, fmt.Sprintf("Task %d not found", task.ID), http.StatusNotFound)
+		} else if errors.Is(err, db.ErrVersionMismatch) {
+			http.Error(w, "Task was modified, fetch it again", http.StatusPreconditionFailed)
+		} else if errors.Is(err, db.ErrInvalidTransition) || errors.Is(err, db.ErrTaskBlocked) {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else if errors.Is(err, db.ErrProjectNotFound) {
+			http.Error(w, fmt.Sprintf("Project %d not found", *task.ProjectID), http.StatusUnprocessableEntity)
 		} else {
 			log.Printf("Failed to update task in DB: %v", err)
 			http.Error(w, fmt.Sprintf("Failed to update task in DB: %v", err), http.StatusInternalServerError)
@@ -189,26 +518,42 @@ func (h *Handler) UpdateTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err = h.Cache.Delete(task.ID); err != nil {
+	if err = h.Cache.Delete(userID, task.ID); err != nil {
 		log.Printf("Failed to delete from cache: %v", err)
 	}
 
+	w.Header().Set("ETag", taskETag(updatedTask))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(updatedTask)
 }
 
+// DeleteTaskHandler moves a task to the trash. Its comments and attachments
+// are hidden while it is there and removed when the trash is purged.
 func (h *Handler) DeleteTaskHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := taskOwnerID(w, r)
+	if !ok {
+		return
+	}
+
 	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
 		http.Error(w, "Invalid task ID", http.StatusBadRequest)
 		return
 	}
 
-	err = h.DB.DeleteTask(id)
+	version, err := h.ifMatchVersion(r, userID, id)
+	if err != nil {
+		ifMatchError(w, err)
+		return
+	}
+
+	err = h.DB.DeleteTask(userID, id, version, taskActorID(r))
 	if err != nil {
 		if errors.Is(err, db.ErrTaskNotFound) {
 			http.Error(w, fmt.Sprintf("Task %d not found", id), http.StatusNotFound)
+		} else if errors.Is(err, db.ErrVersionMismatch) {
+			http.Error(w, "Task was modified, fetch it again", http.StatusPreconditionFailed)
 		} else {
 			log.Printf("Failed to delete task from DB: %v", err)
 			http.Error(w, fmt.Sprintf("Failed to delete from DB: %v", err), http.StatusInternalServerError)
@@ -216,7 +561,7 @@ func (h *Handler) DeleteTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err = h.Cache.Delete(id); err != nil {
+	if err = h.Cache.Delete(userID, id); err != nil {
 		log.Printf("Failed to delete from cache: %v", err)
 	}
 
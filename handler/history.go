@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	db "restapi/db"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// GetTaskHistoryHandler returns every recorded revision of a task, oldest
+// first.
+func (h *Handler) GetTaskHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := taskOwnerID(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	revisions, err := h.DB.GetTaskRevisions(userID, id)
+	if err != nil {
+		if errors.Is(err, db.ErrTaskNotFound) {
+			http.Error(w, fmt.Sprintf("Task %d not found", id), http.StatusNotFound)
+		} else {
+			log.Printf("Failed to get task history: %v", err)
+			http.Error(w, fmt.Sprintf("Failed to get task history: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(revisions)
+}
+
+// RevertTaskHandler restores the name, description, status, priority and due
+// date a task had at the given revision.
+func (h *Handler) RevertTaskHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := taskOwnerID(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	rev, err := strconv.Atoi(mux.Vars(r)["rev"])
+	if err != nil {
+		http.Error(w, "Invalid revision", http.StatusBadRequest)
+		return
+	}
+
+	task, err := h.DB.RevertTask(userID, id, rev, taskActorID(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrTaskNotFound):
+			http.Error(w, fmt.Sprintf("Task %d not found", id), http.StatusNotFound)
+		case errors.Is(err, db.ErrRevisionNotFound):
+			http.Error(w, fmt.Sprintf("Revision %d of task %d not found", rev, id), http.StatusNotFound)
+		case errors.Is(err, db.ErrInvalidTransition), errors.Is(err, db.ErrTaskBlocked):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, db.ErrProjectNotFound):
+			http.Error(w, fmt.Sprintf("Project of revision %d no longer exists", rev), http.StatusConflict)
+		default:
+			log.Printf("Failed to revert task: %v", err)
+			http.Error(w, fmt.Sprintf("Failed to revert task: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err = h.Cache.Delete(userID, id); err != nil {
+		log.Printf("Failed to delete from cache: %v", err)
+	}
+
+	w.Header().Set("ETag", taskETag(task))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(task)
+}
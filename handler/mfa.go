@@ -0,0 +1,281 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"restapi/auth"
+	db "restapi/db"
+	"strconv"
+	"time"
+)
+
+type totpEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type totpCodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type mfaLoginRequest struct {
+	MFAToken string `json:"mfa_token"`
+	totpCodeRequest
+}
+
+func totpIssuer() string {
+	if issuer := os.Getenv("TOTP_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "REST-API"
+}
+
+func (h *Handler) EnrollTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := interactiveClaims(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.DB.GetUser(claims.UserID)
+	if err != nil {
+		log.Printf("Failed to get user: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to get user: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to generate TOTP secret: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.DB.SetTOTPSecret(user.ID, secret); err != nil {
+		if errors.Is(err, db.ErrTOTPAlreadyEnabled) {
+			http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+			return
+		}
+		log.Printf("Failed to store TOTP secret: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to store TOTP secret: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(totpEnrollResponse{
+		Secret:     secret,
+		OTPAuthURI: auth.TOTPURI(totpIssuer(), user.Login, secret),
+	})
+}
+
+func (h *Handler) ConfirmTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	var req totpCodeRequest
+
+	claims, ok := interactiveClaims(w, r)
+	if !ok {
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	state, err := h.DB.GetTOTP(claims.UserID)
+	if err != nil {
+		if errors.Is(err, db.ErrTOTPNotEnrolled) {
+			http.Error(w, "Two-factor authentication enrollment has not been started", http.StatusConflict)
+			return
+		}
+		log.Printf("Failed to get TOTP state: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to get TOTP state: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if state.Enabled {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+
+	valid, err := h.verifyTOTP(claims.UserID, state, req.Code)
+	if err != nil {
+		log.Printf("Failed to verify TOTP code: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to verify TOTP code: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if !valid {
+		http.Error(w, "Invalid code", http.StatusUnprocessableEntity)
+		return
+	}
+
+	codes, err := auth.GenerateRecoveryCodes()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to generate recovery codes: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = auth.HashRecoveryCode(code)
+	}
+
+	if err := h.DB.EnableTOTP(claims.UserID, hashes); err != nil {
+		log.Printf("Failed to enable TOTP: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to enable TOTP: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string][]string{
+		"recovery_codes": codes,
+	})
+}
+
+func (h *Handler) DisableTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	var req totpCodeRequest
+
+	claims, ok := interactiveClaims(w, r)
+	if !ok {
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	valid, err := h.verifySecondFactor(claims.UserID, &req)
+	if err != nil {
+		log.Printf("Failed to verify second factor: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to verify second factor: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if !valid {
+		http.Error(w, "Invalid code", http.StatusUnprocessableEntity)
+		return
+	}
+
+	if err := h.DB.DisableTOTP(claims.UserID); err != nil {
+		log.Printf("Failed to disable TOTP: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to disable TOTP: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) LoginMFAHandler(w http.ResponseWriter, r *http.Request) {
+	var req mfaLoginRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	claims, err := auth.ValidateMFAToken(req.MFAToken)
+	if err != nil {
+		http.Error(w, "Invalid MFA token", http.StatusUnauthorized)
+		return
+	}
+
+	revoked, err := h.Revoked.IsRevoked(claims.ID, claims.UserID, claims.IssuedAt.Time)
+	if err != nil {
+		log.Printf("Failed to check token revocation: %v", err)
+	}
+	if revoked {
+		http.Error(w, "Invalid MFA token", http.StatusUnauthorized)
+		return
+	}
+
+	limiterKey := fmt.Sprintf("mfa:%d", claims.UserID)
+	ip := clientIP(r)
+
+	wait, err := h.Limiter.Check(limiterKey, ip)
+	if err != nil {
+		log.Printf("Failed to check MFA attempts: %v", err)
+	}
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		http.Error(w, "Too many failed attempts, try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	valid, err := h.verifySecondFactor(claims.UserID, &req.totpCodeRequest)
+	if err != nil {
+		log.Printf("Failed to verify second factor: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to verify second factor: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if !valid {
+		if err := h.Limiter.RegisterFailure(limiterKey, ip); err != nil {
+			log.Printf("Failed to register failed MFA attempt: %v", err)
+		}
+		http.Error(w, "Invalid code", http.StatusUnauthorized)
+		return
+	}
+
+	// The MFA token is single-use.
+	if err := h.Revoked.Revoke(claims.ID, time.Until(claims.ExpiresAt.Time)); err != nil {
+		log.Printf("Failed to revoke MFA token: %v", err)
+	}
+	if err := h.Limiter.Reset(limiterKey); err != nil {
+		log.Printf("Failed to reset failed MFA attempts: %v", err)
+	}
+
+	user, err := h.DB.GetUser(claims.UserID)
+	if err != nil {
+		log.Printf("Failed to get user: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to get user: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	h.issueTokens(w, user)
+}
+
+// verifySecondFactor accepts either a current TOTP code or an unused
+// recovery code.
+func (h *Handler) verifySecondFactor(userID int, req *totpCodeRequest) (bool, error) {
+	if req.RecoveryCode != "" {
+		err := h.DB.UseRecoveryCode(userID, auth.HashRecoveryCode(req.RecoveryCode))
+		if errors.Is(err, db.ErrInvalidRecoveryCode) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	state, err := h.DB.GetTOTP(userID)
+	if err != nil {
+		if errors.Is(err, db.ErrTOTPNotEnrolled) {
+			return false, nil
+		}
+		return false, err
+	}
+	if !state.Enabled {
+		return false, nil
+	}
+
+	return h.verifyTOTP(userID, state, req.Code)
+}
+
+func (h *Handler) verifyTOTP(userID int, state *db.TOTPState, code string) (bool, error) {
+	step, ok := auth.ValidateTOTP(state.Secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	if err := h.DB.UseTOTPStep(userID, step); err != nil {
+		if errors.Is(err, db.ErrTOTPCodeReused) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"restapi/auth"
+	db "restapi/db"
+	"time"
+)
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type resetRequest struct {
+	Login string `json:"login"`
+}
+
+type resetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// ChangePasswordHandler sets a new password and revokes every session of
+// the user. The caller gets a fresh token pair so only the other sessions
+// are logged out.
+func (h *Handler) ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req changePasswordRequest
+
+	claims, ok := interactiveClaims(w, r)
+	if !ok {
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	if err := db.ValidatePassword(req.NewPassword); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.DB.ChangePassword(claims.UserID, req.CurrentPassword, req.NewPassword); err != nil {
+		if errors.Is(err, db.ErrIncorrectPassword) {
+			http.Error(w, "Incorrect current password", http.StatusForbidden)
+			return
+		}
+		log.Printf("Failed to change password: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to change password: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.revokeAllSessions(claims.UserID); err != nil {
+		log.Printf("Failed to revoke sessions: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to revoke sessions: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	user, err := h.DB.GetUser(claims.UserID)
+	if err != nil {
+		log.Printf("Failed to get user: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to get user: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	h.issueTokens(w, user)
+}
+
+// RequestPasswordResetHandler always answers 202 so that it cannot be used
+// to find out which logins exist.
+func (h *Handler) RequestPasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	var req resetRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	if req.Login == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	token, err := auth.GenerateResetToken()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to generate reset token: %v", err), http.StatusInternalServerError)
+		return
+	}
+	expiresAt := time.Now().Add(auth.ResetTokenTTL)
+
+	user, err := h.DB.AddPasswordResetToken(req.Login, auth.HashResetToken(token), expiresAt)
+	switch {
+	case err == nil:
+		if err := h.Notifier.SendPasswordReset(user.Login, token, expiresAt); err != nil {
+			log.Printf("Failed to send password reset to user %d: %v", user.ID, err)
+		}
+	case !errors.Is(err, db.ErrUserNotFound):
+		log.Printf("Failed to store password reset token: %v", err)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *Handler) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req resetPasswordRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	if req.Token == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := db.ValidatePassword(req.NewPassword); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userID, err := h.DB.ResetPassword(auth.HashResetToken(req.Token), req.NewPassword)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidResetToken) {
+			http.Error(w, "Invalid or expired reset token", http.StatusBadRequest)
+			return
+		}
+		log.Printf("Failed to reset password: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to reset password: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.revokeAllSessions(userID); err != nil {
+		log.Printf("Failed to revoke sessions of user %d: %v", userID, err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	bt "restapi/basic_types"
+	db "restapi/db"
+	"restapi/patch"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+const maxPatchSize = 1 << 20
+
+// PatchTaskHandler applies an RFC 7396 merge patch or an RFC 6902 JSON patch,
+// chosen by Content-Type, to a task. The patch runs inside the store's
+// transaction, so it always sees the current version of the task.
+func (h *Handler) PatchTaskHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := taskOwnerID(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id == 0 {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPatchSize))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	versions, err := ifMatchVersions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var apply func(doc []byte) ([]byte, error)
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch contentType {
+	case patch.MergePatchContentType:
+		if !json.Valid(body) {
+			http.Error(w, "Invalid merge patch document", http.StatusBadRequest)
+			return
+		}
+		apply = func(doc []byte) ([]byte, error) {
+			return patch.MergePatch(doc, body)
+		}
+	case patch.JSONPatchContentType:
+		p, err := patch.DecodeJSONPatch(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		apply = p.Apply
+	default:
+		w.Header().Set("Accept-Patch", patch.MergePatchContentType+", "+patch.JSONPatchContentType)
+		http.Error(w, "Unsupported patch format", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	updatedTask, err := h.DB.PatchTask(userID, id, taskActorID(r), func(task *bt.Task) error {
+		if !matchesVersion(versions, task.Version) {
+			return db.ErrVersionMismatch
+		}
+		return patchTask(task, apply)
+	})
+	if err != nil {
+		var te *taskError
+		switch {
+		case errors.As(err, &te):
+			http.Error(w, te.message, te.status)
+		case errors.Is(err, db.ErrTaskNotFound):
+			http.Error(w, fmt.Sprintf("Task %d not found", id), http.StatusNotFound)
+		case errors.Is(err, db.ErrVersionMismatch):
+			http.Error(w, "Task was modified, fetch it again", http.StatusPreconditionFailed)
+		case errors.Is(err, db.ErrInvalidTransition), errors.Is(err, db.ErrTaskBlocked), errors.Is(err, patch.ErrTestFailed):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, patch.ErrPathNotFound), errors.Is(err, patch.ErrInvalidPatch), errors.Is(err, db.ErrProjectNotFound):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		default:
+			log.Printf("Failed to patch task in DB: %v", err)
+			http.Error(w, fmt.Sprintf("Failed to patch task in DB: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err = h.Cache.Delete(userID, id); err != nil {
+		log.Printf("Failed to delete from cache: %v", err)
+	}
+
+	w.Header().Set("ETag", taskETag(updatedTask))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(updatedTask)
+}
+
+// patchTask applies a patch to the JSON form of task and validates the
+// result like a PUT body. Writes to server-managed fields are ignored.
+func patchTask(task *bt.Task, apply func(doc []byte) ([]byte, error)) error {
+	doc, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+
+	patched, err := apply(doc)
+	if err != nil {
+		return err
+	}
+
+	var result bt.Task
+	if err := json.Unmarshal(patched, &result); err != nil {
+		return &taskError{http.StatusUnprocessableEntity, fmt.Sprintf("Patched task is invalid: %v", err)}
+	}
+	if err := checkTask(&result); err != nil {
+		return err
+	}
+	if result.Status == "" {
+		return &taskError{http.StatusUnprocessableEntity, "Task status cannot be removed"}
+	}
+
+	*task = result
+	return nil
+}
@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	bt "restapi/basic_types"
+	db "restapi/db"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// decodeProject reads a project from the request body. Only the name and
+// description are taken from the client.
+func decodeProject(w http.ResponseWriter, r *http.Request) (*bt.Project, bool) {
+	var body bt.Project
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return nil, false
+	}
+	defer r.Body.Close()
+
+	project := &bt.Project{Name: strings.TrimSpace(body.Name), Description: body.Description}
+	if project.Name == "" {
+		http.Error(w, "Invalid request body: name is required", http.StatusBadRequest)
+		return nil, false
+	}
+	return project, true
+}
+
+func projectID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["projectID"])
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+func projectError(w http.ResponseWriter, err error, id int, action string) {
+	if errors.Is(err, db.ErrProjectNotFound) {
+		http.Error(w, fmt.Sprintf("Project %d not found", id), http.StatusNotFound)
+		return
+	}
+	log.Printf("Failed to %s: %v", action, err)
+	http.Error(w, fmt.Sprintf("Failed to %s: %v", action, err), http.StatusInternalServerError)
+}
+
+func (h *Handler) CreateProjectHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := taskOwnerID(w, r)
+	if !ok {
+		return
+	}
+
+	project, ok := decodeProject(w, r)
+	if !ok {
+		return
+	}
+	project.OwnerID = userID
+
+	if err := h.DB.CreateProject(project); err != nil {
+		projectError(w, err, 0, "create project")
+		return
+	}
+
+	w.Header().Set("Location", path.Join(r.URL.Path, strconv.Itoa(project.ID)))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(project)
+}
+
+func (h *Handler) GetProjectHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := taskOwnerID(w, r)
+	if !ok {
+		return
+	}
+
+	id, ok := projectID(w, r)
+	if !ok {
+		return
+	}
+
+	project, err := h.DB.GetProject(userID, id)
+	if err != nil {
+		projectError(w, err, id, "get project")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(project)
+}
+
+func (h *Handler) GetAllProjectsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := taskOwnerID(w, r)
+	if !ok {
+		return
+	}
+
+	projects, err := h.DB.GetAllProjects(userID)
+	if err != nil {
+		projectError(w, err, 0, "get projects")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(projects)
+}
+
+func (h *Handler) UpdateProjectHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := taskOwnerID(w, r)
+	if !ok {
+		return
+	}
+
+	id, ok := projectID(w, r)
+	if !ok {
+		return
+	}
+
+	project, ok := decodeProject(w, r)
+	if !ok {
+		return
+	}
+	project.ID, project.OwnerID = id, userID
+
+	updated, err := h.DB.UpdateProject(project)
+	if err != nil {
+		projectError(w, err, id, "update project")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeleteProjectHandler removes a project. The tasks query parameter chooses
+// what happens to its tasks: "reassign" (the default) moves them to the
+// inbox, "cascade" moves them to the trash.
+func (h *Handler) DeleteProjectHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := taskOwnerID(w, r)
+	if !ok {
+		return
+	}
+
+	id, ok := projectID(w, r)
+	if !ok {
+		return
+	}
+
+	var cascade bool
+	switch r.URL.Query().Get("tasks") {
+	case "", "reassign":
+	case "cascade":
+		cascade = true
+	default:
+		http.Error(w, `tasks must be "reassign" or "cascade"`, http.StatusBadRequest)
+		return
+	}
+
+	taskIDs, err := h.DB.DeleteProject(userID, id, cascade, taskActorID(r))
+	if err != nil {
+		projectError(w, err, id, "delete project")
+		return
+	}
+
+	for _, taskID := range taskIDs {
+		if err := h.Cache.Delete(userID, taskID); err != nil {
+			log.Printf("Failed to delete from cache: %v", err)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetProjectTasksHandler lists the tasks of a project. It accepts the same
+// query parameters as GET /tasks.
+func (h *Handler) GetProjectTasksHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := taskOwnerID(w, r)
+	if !ok {
+		return
+	}
+
+	id, ok := projectID(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := h.DB.GetProject(userID, id); err != nil {
+		projectError(w, err, id, "get project")
+		return
+	}
+
+	h.listTasks(w, r, false, id)
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	db "restapi/db"
+	"strconv"
+	"strings"
+)
+
+// SearchTasksHandler serves GET /tasks/search?q=...&limit=..., returning the
+// owner's tasks ordered by relevance.
+func (h *Handler) SearchTasksHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := taskOwnerID(w, r)
+	if !ok {
+		return
+	}
+
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		http.Error(w, "Missing search query", http.StatusBadRequest)
+		return
+	}
+
+	limit := db.DefaultTaskPageSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, db.MaxTaskPageSize)
+	}
+
+	results, err := h.DB.SearchTasks(userID, q, limit)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidTaskQuery) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Failed to search tasks: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to search tasks: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(results)
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	bt "restapi/basic_types"
+	db "restapi/db"
+	"slices"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+var errTagNotFound = errors.New("task does not have this tag")
+
+// GetTagsHandler lists the caller's tags with the number of tasks outside
+// the trash that carry each of them.
+func (h *Handler) GetTagsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := taskOwnerID(w, r)
+	if !ok {
+		return
+	}
+
+	tags, err := h.DB.GetTags(userID)
+	if err != nil {
+		log.Printf("Failed to get tags from DB: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to get tags from DB: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tags)
+}
+
+// AddTaskTagHandler attaches the tag from the URL to a task. Adding a tag
+// the task already has is not an error.
+func (h *Handler) AddTaskTagHandler(w http.ResponseWriter, r *http.Request) {
+	h.changeTaskTags(w, r, func(tags []string, tag string) ([]string, error) {
+		return append(tags, tag), nil
+	})
+}
+
+// RemoveTaskTagHandler detaches the tag from the URL from a task.
+func (h *Handler) RemoveTaskTagHandler(w http.ResponseWriter, r *http.Request) {
+	h.changeTaskTags(w, r, func(tags []string, tag string) ([]string, error) {
+		i := slices.Index(tags, tag)
+		if i < 0 {
+			return nil, errTagNotFound
+		}
+		return slices.Delete(tags, i, i+1), nil
+	})
+}
+
+func (h *Handler) changeTaskTags(w http.ResponseWriter, r *http.Request, change func(tags []string, tag string) ([]string, error)) {
+	userID, ok := taskOwnerID(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	tag, err := bt.NormalizeTag(mux.Vars(r)["tag"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	task, err := h.DB.PatchTask(userID, id, taskActorID(r), func(task *bt.Task) error {
+		tags, err := change(slices.Clone(task.Tags), tag)
+		if err != nil {
+			return err
+		}
+		task.Tags = tags
+		return nil
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrTaskNotFound):
+			http.Error(w, fmt.Sprintf("Task %d not found", id), http.StatusNotFound)
+		case errors.Is(err, errTagNotFound):
+			http.Error(w, fmt.Sprintf("Task %d has no tag %q", id, tag), http.StatusNotFound)
+		case errors.Is(err, db.ErrInvalidTag):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		default:
+			log.Printf("Failed to change task tags: %v", err)
+			http.Error(w, fmt.Sprintf("Failed to change task tags: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err = h.Cache.Delete(userID, id); err != nil {
+		log.Printf("Failed to delete from cache: %v", err)
+	}
+
+	w.Header().Set("ETag", taskETag(task))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(task)
+}
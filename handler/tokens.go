@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"restapi/auth"
+	db "restapi/db"
+	"time"
+)
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type tokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+func (h *Handler) RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	if req.RefreshToken == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	refreshToken, err := auth.GenerateRefreshToken()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to generate refresh token: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	userID, err := h.DB.RotateRefreshToken(auth.HashRefreshToken(req.RefreshToken),
+		auth.HashRefreshToken(refreshToken), time.Now().Add(auth.RefreshTokenTTL))
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrRefreshTokenNotFound), errors.Is(err, db.ErrRefreshTokenExpired):
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		case errors.Is(err, db.ErrRefreshTokenReused):
+			log.Printf("Refresh token reuse detected, token family revoked")
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		default:
+			log.Printf("Failed to rotate refresh token: %v", err)
+			http.Error(w, fmt.Sprintf("Failed to rotate refresh token: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	user, err := h.DB.GetUser(userID)
+	if err != nil {
+		if errors.Is(err, db.ErrUserNotFound) {
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+		log.Printf("Failed to get user: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to get user: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeTokens(w, user, refreshToken, http.StatusOK)
+}
+
+// issueTokens starts a new refresh token family for user and writes
+// the access/refresh token pair.
+func (h *Handler) issueTokens(w http.ResponseWriter, user *db.User) {
+	refreshToken, err := auth.GenerateRefreshToken()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to generate refresh token: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	err = h.DB.AddRefreshToken(user.ID, auth.HashRefreshToken(refreshToken), time.Now().Add(auth.RefreshTokenTTL))
+	if err != nil {
+		log.Printf("Failed to store refresh token: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to store refresh token: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeTokens(w, user, refreshToken, http.StatusCreated)
+}
+
+func writeTokens(w http.ResponseWriter, user *db.User, refreshToken string, status int) {
+	token, err := auth.GenerateToken(user.ID, user.Role)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to generate JWT token: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Authorization", "Bearer "+token)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(tokenResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(auth.AccessTokenTTL.Seconds()),
+	})
+}
+
+// LogoutHandler revokes the access token of the request and, if one is
+// sent, a refresh token. Only JWT sessions can log out.
+func (h *Handler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := interactiveClaims(w, r)
+	if !ok {
+		return
+	}
+
+	var req refreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	ttl := auth.AccessTokenTTL
+	if claims.ExpiresAt != nil {
+		ttl = time.Until(claims.ExpiresAt.Time)
+	}
+	if err := h.Revoked.Revoke(claims.ID, ttl); err != nil {
+		log.Printf("Failed to revoke token: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to revoke token: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if req.RefreshToken != "" {
+		if err := h.DB.RevokeRefreshToken(auth.HashRefreshToken(req.RefreshToken)); err != nil {
+			log.Printf("Failed to revoke refresh token: %v", err)
+			http.Error(w, fmt.Sprintf("Failed to revoke refresh token: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) LogoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := interactiveClaims(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.revokeAllSessions(claims.UserID); err != nil {
+		log.Printf("Failed to revoke sessions: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to revoke sessions: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// revokeAllSessions invalidates every access and refresh token issued to
+// userID so far.
+func (h *Handler) revokeAllSessions(userID int) error {
+	if err := h.Revoked.RevokeUser(userID, auth.AccessTokenTTL); err != nil {
+		return err
+	}
+	return h.DB.RevokeUserRefreshTokens(userID)
+}
+
+func (h *Handler) JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(auth.CurrentKeySet().JWKS())
+}
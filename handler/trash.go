@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	db "restapi/db"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// GetTrashHandler lists deleted tasks that have not been purged yet. It
+// accepts the same query parameters as GET /tasks.
+func (h *Handler) GetTrashHandler(w http.ResponseWriter, r *http.Request) {
+	h.listTasks(w, r, true, 0)
+}
+
+// RestoreTaskHandler moves a task out of the trash. Deleted tasks are never
+// cached, so there is nothing to invalidate.
+func (h *Handler) RestoreTaskHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := taskOwnerID(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	task, err := h.DB.RestoreTask(userID, id, taskActorID(r))
+	if err != nil {
+		if errors.Is(err, db.ErrTaskNotFound) {
+			http.Error(w, fmt.Sprintf("Task %d not found in trash", id), http.StatusNotFound)
+		} else {
+			log.Printf("Failed to restore task: %v", err)
+			http.Error(w, fmt.Sprintf("Failed to restore task: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("ETag", taskETag(task))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(task)
+}
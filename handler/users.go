@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"restapi/auth"
+	db "restapi/db"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type roleRequest struct {
+	Role string `json:"role"`
+}
+
+func (h *Handler) GetAllUsersHandler(w http.ResponseWriter, r *http.Request) {
+	users, err := h.DB.GetAllUsers()
+	if err != nil {
+		log.Printf("Failed to get all users from DB: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to get all users from DB: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(users)
+}
+
+func (h *Handler) UpdateUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	var req roleRequest
+
+	userID, err := strconv.Atoi(mux.Vars(r)["userID"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	if !auth.IsValidRole(req.Role) {
+		http.Error(w, fmt.Sprintf("Invalid role %q", req.Role), http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.DB.SetUserRole(userID, req.Role)
+	if err != nil {
+		if errors.Is(err, db.ErrUserNotFound) {
+			http.Error(w, fmt.Sprintf("User %d not found", userID), http.StatusNotFound)
+		} else {
+			log.Printf("Failed to update user role in DB: %v", err)
+			http.Error(w, fmt.Sprintf("Failed to update user role in DB: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Access tokens carry the role, so drop the ones already issued; the
+	// user's refresh tokens stay valid and pick up the new role.
+	if err := h.Revoked.RevokeUser(userID, auth.AccessTokenTTL); err != nil {
+		log.Printf("Failed to revoke tokens of user %d: %v", userID, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(user)
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 
+	"restapi/auth"
+	bt "restapi/basic_types"
+	db "restapi/db"
 	"restapi/handler"
 
 	"github.com/gorilla/mux"
@@ -16,22 +20,101 @@ func main() {
 		log.Fatal(err)
 	}
 
+	keys, err := auth.LoadKeySetFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	auth.SetKeySet(keys)
+
+	if err := bt.LoadTransitionsFromEnv(); err != nil {
+		log.Fatal(err)
+	}
+
 	h, err := handler.NewHandler()
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	purger, err := db.NewPurgerFromEnv(h.DB, h.Blobs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	go purger.Run(context.Background())
+
 	r := mux.NewRouter()
 	r.HandleFunc("/login", h.LoginHandler).Methods("POST")
+	r.HandleFunc("/login/mfa", h.LoginMFAHandler).Methods("POST")
+	r.HandleFunc("/register", h.RegisterHandler).Methods("POST")
+	r.HandleFunc("/token/refresh", h.RefreshTokenHandler).Methods("POST")
+	r.HandleFunc("/password/reset/request", h.RequestPasswordResetHandler).Methods("POST")
+	r.HandleFunc("/password/reset", h.ResetPasswordHandler).Methods("POST")
+	r.HandleFunc("/.well-known/jwks.json", h.JWKSHandler).Methods("GET")
 
 	api := r.NewRoute().Subrouter()
 	api.Use(h.AuthorizationMiddleware)
 
-	api.HandleFunc("/tasks/{id:[0-9]+}", h.CreateTaskHandler).Methods("POST")
-	api.HandleFunc("/tasks/{id:[0-9]+}", h.GetTaskHandler).Methods("GET")
-	api.HandleFunc("/tasks", h.GetAllTasksHandler).Methods("GET")
-	api.HandleFunc("/tasks/{id:[0-9]+}", h.UpdateTaskHandler).Methods("PUT")
-	api.HandleFunc("/tasks/{id:[0-9]+}", h.DeleteTaskHandler).Methods("DELETE")
+	api.HandleFunc("/logout", h.LogoutHandler).Methods("POST")
+	api.HandleFunc("/logout/all", h.LogoutAllHandler).Methods("POST")
+
+	api.HandleFunc("/me/password", h.ChangePasswordHandler).Methods("POST")
+	api.HandleFunc("/me/mfa/totp", h.EnrollTOTPHandler).Methods("POST")
+	api.HandleFunc("/me/mfa/totp/confirm", h.ConfirmTOTPHandler).Methods("POST")
+	api.HandleFunc("/me/mfa/totp", h.DisableTOTPHandler).Methods("DELETE")
+
+	api.HandleFunc("/api-keys", h.CreateAPIKeyHandler).Methods("POST")
+	api.HandleFunc("/api-keys", h.GetAllAPIKeysHandler).Methods("GET")
+	api.HandleFunc("/api-keys/{id:[0-9]+}", h.DeleteAPIKeyHandler).Methods("DELETE")
+
+	allow := func(perm auth.Permission, f http.HandlerFunc) http.Handler {
+		return h.RequirePermission(perm)(f)
+	}
+
+	taskRoutes := func(r *mux.Router) {
+		r.Handle("/tasks", allow(auth.PermTasksWrite, h.CreateTaskHandler)).Methods("POST")
+		r.Handle("/tasks/{id:[0-9]+}", allow(auth.PermTasksWrite, h.CreateTaskHandler)).Methods("POST")
+		r.Handle("/tasks/{id:[0-9]+}", allow(auth.PermTasksRead, h.GetTaskHandler)).Methods("GET")
+		r.Handle("/tasks", allow(auth.PermTasksRead, h.GetAllTasksHandler)).Methods("GET")
+		r.Handle("/tasks/search", allow(auth.PermTasksRead, h.SearchTasksHandler)).Methods("GET")
+		r.Handle("/tasks/{id:[0-9]+}", allow(auth.PermTasksWrite, h.UpdateTaskHandler)).Methods("PUT")
+		r.Handle("/tasks/{id:[0-9]+}", allow(auth.PermTasksWrite, h.PatchTaskHandler)).Methods("PATCH")
+		r.Handle("/tasks/{id:[0-9]+}", allow(auth.PermTasksWrite, h.DeleteTaskHandler)).Methods("DELETE")
+		r.Handle("/tasks/{id:[0-9]+}/restore", allow(auth.PermTasksWrite, h.RestoreTaskHandler)).Methods("POST")
+		r.Handle("/tasks/{id:[0-9]+}/history", allow(auth.PermTasksRead, h.GetTaskHistoryHandler)).Methods("GET")
+		r.Handle("/tasks/{id:[0-9]+}/revert/{rev:[0-9]+}", allow(auth.PermTasksWrite, h.RevertTaskHandler)).Methods("POST")
+		r.Handle("/tasks/{id:[0-9]+}/comments", allow(auth.PermTasksWrite, h.CreateCommentHandler)).Methods("POST")
+		r.Handle("/tasks/{id:[0-9]+}/comments", allow(auth.PermTasksRead, h.GetCommentsHandler)).Methods("GET")
+		r.Handle("/tasks/{id:[0-9]+}/comments/{commentID:[0-9]+}", allow(auth.PermTasksWrite, h.UpdateCommentHandler)).Methods("PUT")
+		r.Handle("/tasks/{id:[0-9]+}/comments/{commentID:[0-9]+}", allow(auth.PermTasksWrite, h.DeleteCommentHandler)).Methods("DELETE")
+		r.Handle("/tasks/{id:[0-9]+}/attachments", allow(auth.PermTasksWrite, h.UploadAttachmentHandler)).Methods("POST")
+		r.Handle("/tasks/{id:[0-9]+}/attachments", allow(auth.PermTasksRead, h.GetAttachmentsHandler)).Methods("GET")
+		r.Handle("/tasks/{id:[0-9]+}/attachments/{attachmentID:[0-9]+}", allow(auth.PermTasksRead, h.DownloadAttachmentHandler)).Methods("GET")
+		r.Handle("/tasks/{id:[0-9]+}/attachments/{attachmentID:[0-9]+}", allow(auth.PermTasksWrite, h.DeleteAttachmentHandler)).Methods("DELETE")
+		r.Handle("/tasks/{id:[0-9]+}/tree", allow(auth.PermTasksRead, h.GetTaskTreeHandler)).Methods("GET")
+		r.Handle("/tasks/{id:[0-9]+}/parent/{parentID:[0-9]+}", allow(auth.PermTasksWrite, h.SetTaskParentHandler)).Methods("PUT")
+		r.Handle("/tasks/{id:[0-9]+}/parent", allow(auth.PermTasksWrite, h.RemoveTaskParentHandler)).Methods("DELETE")
+		r.Handle("/tasks/{id:[0-9]+}/blockers/{blockerID:[0-9]+}", allow(auth.PermTasksWrite, h.AddTaskBlockerHandler)).Methods("PUT")
+		r.Handle("/tasks/{id:[0-9]+}/blockers/{blockerID:[0-9]+}", allow(auth.PermTasksWrite, h.RemoveTaskBlockerHandler)).Methods("DELETE")
+		r.Handle("/tasks/{id:[0-9]+}/tags/{tag}", allow(auth.PermTasksWrite, h.AddTaskTagHandler)).Methods("PUT")
+		r.Handle("/tasks/{id:[0-9]+}/tags/{tag}", allow(auth.PermTasksWrite, h.RemoveTaskTagHandler)).Methods("DELETE")
+		r.Handle("/tags", allow(auth.PermTasksRead, h.GetTagsHandler)).Methods("GET")
+		r.Handle("/projects", allow(auth.PermTasksWrite, h.CreateProjectHandler)).Methods("POST")
+		r.Handle("/projects", allow(auth.PermTasksRead, h.GetAllProjectsHandler)).Methods("GET")
+		r.Handle("/projects/{projectID:[0-9]+}", allow(auth.PermTasksRead, h.GetProjectHandler)).Methods("GET")
+		r.Handle("/projects/{projectID:[0-9]+}", allow(auth.PermTasksWrite, h.UpdateProjectHandler)).Methods("PUT")
+		r.Handle("/projects/{projectID:[0-9]+}", allow(auth.PermTasksWrite, h.DeleteProjectHandler)).Methods("DELETE")
+		r.Handle("/projects/{projectID:[0-9]+}/tasks", allow(auth.PermTasksRead, h.GetProjectTasksHandler)).Methods("GET")
+		r.Handle("/projects/{projectID:[0-9]+}/tasks", allow(auth.PermTasksWrite, h.CreateTaskHandler)).Methods("POST")
+		r.Handle("/trash", allow(auth.PermTasksRead, h.GetTrashHandler)).Methods("GET")
+	}
+
+	taskRoutes(api)
+
+	admin := api.PathPrefix("/users/{userID:[0-9]+}").Subrouter()
+	admin.Use(h.RequirePermission(auth.PermTasksAll))
+	taskRoutes(admin)
+
+	api.Handle("/users", allow(auth.PermUsersManage, h.GetAllUsersHandler)).Methods("GET")
+	api.Handle("/users/{userID:[0-9]+}/role", allow(auth.PermUsersManage, h.UpdateUserRoleHandler)).Methods("PUT")
 
 	log.Println("Starting server at :8080")
 	log.Fatal(http.ListenAndServe(":8080", r))
@@ -0,0 +1,54 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// FileNotifier appends notifications as JSON lines to a file, which local
+// tooling or tests can read instead of a mailbox.
+type FileNotifier struct {
+	mu   sync.Mutex
+	path string
+}
+
+type notification struct {
+	Type      string    `json:"type"`
+	Login     string    `json:"login"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	SentAt    time.Time `json:"sent_at"`
+}
+
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{path: path}
+}
+
+func (fn *FileNotifier) SendPasswordReset(login, token string, expiresAt time.Time) error {
+	return fn.write(notification{
+		Type:      "password_reset",
+		Login:     login,
+		Token:     token,
+		ExpiresAt: expiresAt,
+		SentAt:    time.Now(),
+	})
+}
+
+func (fn *FileNotifier) write(n notification) error {
+	fn.mu.Lock()
+	defer fn.mu.Unlock()
+
+	f, err := os.OpenFile(fn.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open notification file: %v", err)
+	}
+	defer f.Close()
+
+	if err := json.NewEncoder(f).Encode(n); err != nil {
+		return fmt.Errorf("failed to write notification: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package notify
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+type Notifier interface {
+	SendPasswordReset(login, token string, expiresAt time.Time) error
+}
+
+// NewNotifierFromEnv picks the notifier configured by NOTIFIER: "log"
+// (default) or "file", which appends to NOTIFIER_FILE.
+func NewNotifierFromEnv() (Notifier, error) {
+	switch kind := os.Getenv("NOTIFIER"); kind {
+	case "", "log":
+		return &LogNotifier{}, nil
+	case "file":
+		path := os.Getenv("NOTIFIER_FILE")
+		if path == "" {
+			return nil, fmt.Errorf("NOTIFIER_FILE must be set for the file notifier")
+		}
+		return NewFileNotifier(path), nil
+	default:
+		return nil, fmt.Errorf("unknown notifier %q", kind)
+	}
+}
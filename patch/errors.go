@@ -0,0 +1,9 @@
+package patch
+
+import "errors"
+
+var (
+	ErrInvalidPatch = errors.New("invalid patch document")
+	ErrPathNotFound = errors.New("patch path not found")
+	ErrTestFailed   = errors.New("patch test operation failed")
+)
@@ -0,0 +1,297 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Operation is a single RFC 6902 operation. Value stays raw so that an
+// explicit null can be told apart from a missing member.
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// JSONPatch is an RFC 6902 JSON Patch: a list of operations applied in
+// order, all or nothing.
+type JSONPatch []Operation
+
+func DecodeJSONPatch(data []byte) (JSONPatch, error) {
+	var p JSONPatch
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	for i, op := range p {
+		if op.Path == nil {
+			return nil, fmt.Errorf("%w: operation %d has no path", ErrInvalidPatch, i)
+		}
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				return nil, fmt.Errorf("%w: %s operation %d has no value", ErrInvalidPatch, op.Op, i)
+			}
+		case "move", "copy":
+			if op.From == nil {
+				return nil, fmt.Errorf("%w: %s operation %d has no from", ErrInvalidPatch, op.Op, i)
+			}
+		case "remove":
+		default:
+			return nil, fmt.Errorf("%w: unknown operation %q", ErrInvalidPatch, op.Op)
+		}
+	}
+	return p, nil
+}
+
+// Apply returns doc with every operation applied. doc itself is not
+// modified, so a failing operation leaves nothing half-applied.
+func (p JSONPatch) Apply(doc []byte) ([]byte, error) {
+	var root interface{}
+	if err := json.Unmarshal(doc, &root); err != nil {
+		return nil, fmt.Errorf("invalid document: %v", err)
+	}
+
+	for i, op := range p {
+		var err error
+		if root, err = op.apply(root); err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, *op.Path, err)
+		}
+	}
+	return json.Marshal(root)
+}
+
+func (op Operation) apply(root interface{}) (interface{}, error) {
+	path, err := parsePointer(*op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	if op.Value != nil {
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+	}
+
+	switch op.Op {
+	case "add":
+		return add(root, path, value)
+	case "remove":
+		root, _, err = remove(root, path)
+		return root, err
+	case "replace":
+		if len(path) == 0 {
+			return value, nil
+		}
+		if _, err := get(root, path); err != nil {
+			return nil, err
+		}
+		if root, _, err = remove(root, path); err != nil {
+			return nil, err
+		}
+		return add(root, path, value)
+	case "test":
+		current, err := get(root, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(current, value) {
+			return nil, ErrTestFailed
+		}
+		return root, nil
+	}
+
+	from, err := parsePointer(*op.From)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "move":
+		if isProperPrefix(from, path) {
+			return nil, fmt.Errorf("%w: cannot move a value into one of its children", ErrInvalidPatch)
+		}
+		var moved interface{}
+		if root, moved, err = remove(root, from); err != nil {
+			return nil, err
+		}
+		return add(root, path, moved)
+	case "copy":
+		v, err := get(root, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(root, path, deepCopy(v))
+	}
+	return nil, fmt.Errorf("%w: unknown operation %q", ErrInvalidPatch, op.Op)
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into unescaped tokens.
+func parsePointer(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(s, "/") {
+		return nil, fmt.Errorf("%w: pointer %q must start with /", ErrInvalidPatch, s)
+	}
+
+	tokens := strings.Split(s[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+	return tokens, nil
+}
+
+func isProperPrefix(prefix, path []string) bool {
+	if len(prefix) >= len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// arrayIndex parses an array index token; "-" is only valid for add and is
+// reported as len(a).
+func arrayIndex(a []interface{}, token string, allowEnd bool) (int, error) {
+	if token == "-" && allowEnd {
+		return len(a), nil
+	}
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("%w: invalid array index %q", ErrPathNotFound, token)
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("%w: invalid array index %q", ErrPathNotFound, token)
+	}
+
+	limit := len(a) - 1
+	if allowEnd {
+		limit = len(a)
+	}
+	if i > limit {
+		return 0, fmt.Errorf("%w: array index %d out of range", ErrPathNotFound, i)
+	}
+	return i, nil
+}
+
+func child(node interface{}, token string) (interface{}, error) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		v, ok := n[token]
+		if !ok {
+			return nil, fmt.Errorf("%w: member %q", ErrPathNotFound, token)
+		}
+		return v, nil
+	case []interface{}:
+		i, err := arrayIndex(n, token, false)
+		if err != nil {
+			return nil, err
+		}
+		return n[i], nil
+	}
+	return nil, fmt.Errorf("%w: %q is not inside an object or array", ErrPathNotFound, token)
+}
+
+func get(root interface{}, path []string) (interface{}, error) {
+	node := root
+	for _, token := range path {
+		var err error
+		if node, err = child(node, token); err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+// update replaces the container holding the last token of path with the
+// result of fn and rebuilds the parents, since appending to or shrinking a
+// slice yields a new value.
+func update(node interface{}, path []string, fn func(container interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(node, path[0])
+	}
+
+	c, err := child(node, path[0])
+	if err != nil {
+		return nil, err
+	}
+	newChild, err := update(c, path[1:], fn)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		n[path[0]] = newChild
+	case []interface{}:
+		i, _ := arrayIndex(n, path[0], false)
+		n[i] = newChild
+	}
+	return node, nil
+}
+
+func add(root interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return update(root, path, func(container interface{}, token string) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			c[token] = value
+			return c, nil
+		case []interface{}:
+			i, err := arrayIndex(c, token, true)
+			if err != nil {
+				return nil, err
+			}
+			c = append(c, nil)
+			copy(c[i+1:], c[i:])
+			c[i] = value
+			return c, nil
+		}
+		return nil, fmt.Errorf("%w: %q is not inside an object or array", ErrPathNotFound, token)
+	})
+}
+
+func remove(root interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("%w: cannot remove the whole document", ErrInvalidPatch)
+	}
+
+	var removed interface{}
+	root, err := update(root, path, func(container interface{}, token string) (interface{}, error) {
+		v, err := child(container, token)
+		if err != nil {
+			return nil, err
+		}
+		removed = v
+
+		switch c := container.(type) {
+		case map[string]interface{}:
+			delete(c, token)
+			return c, nil
+		case []interface{}:
+			i, _ := arrayIndex(c, token, false)
+			return append(c[:i], c[i+1:]...), nil
+		}
+		return container, nil
+	})
+	return root, removed, err
+}
+
+func deepCopy(v interface{}) interface{} {
+	data, _ := json.Marshal(v)
+	var c interface{}
+	json.Unmarshal(data, &c)
+	return c
+}
@@ -1,12 +1,167 @@
+CREATE TABLE users (
+    id SERIAL PRIMARY KEY,
+    login TEXT UNIQUE NOT NULL,
+    hash TEXT NOT NULL,
+    role TEXT NOT NULL DEFAULT 'member' CHECK (role IN ('admin', 'member')),
+    totp_secret TEXT,
+    totp_enabled BOOLEAN NOT NULL DEFAULT false,
+    totp_last_step BIGINT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+CREATE TABLE projects (
+    id SERIAL PRIMARY KEY,
+    owner_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    UNIQUE (owner_id, id)
+);
+
 CREATE TABLE tasks (
-    id INTEGER NOT NULL,
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY,
+    owner_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
     name TEXT NOT NULL,
-    description TEXT
+    description TEXT,
+    status TEXT NOT NULL DEFAULT 'todo' CHECK (status IN ('todo', 'in_progress', 'blocked', 'done', 'cancelled')),
+    priority SMALLINT NOT NULL DEFAULT 0 CHECK (priority BETWEEN 0 AND 5),
+    project_id INTEGER,
+    parent_id INTEGER,
+    due_at TIMESTAMP WITH TIME ZONE,
+    started_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    version INTEGER NOT NULL DEFAULT 1,
+    search_language REGCONFIG NOT NULL DEFAULT 'english',
+    search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector(search_language, name), 'A') ||
+        setweight(to_tsvector(search_language, coalesce(description, '')), 'B')
+    ) STORED,
+    PRIMARY KEY (owner_id, id),
+    CONSTRAINT tasks_project_fkey FOREIGN KEY (owner_id, project_id) REFERENCES projects (owner_id, id),
+    -- A column list in ON DELETE SET NULL needs PostgreSQL 15 or later.
+    CONSTRAINT tasks_parent_fkey FOREIGN KEY (owner_id, parent_id) REFERENCES tasks (owner_id, id)
+        ON DELETE SET NULL (parent_id)
 );
 
-CREATE TABLE users (
+CREATE INDEX tasks_search_idx ON tasks USING GIN (search_vector);
+CREATE INDEX tasks_deleted_at_idx ON tasks (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX tasks_project_id_idx ON tasks (owner_id, project_id) WHERE project_id IS NOT NULL;
+CREATE INDEX tasks_parent_id_idx ON tasks (owner_id, parent_id) WHERE parent_id IS NOT NULL;
+
+CREATE TABLE task_dependencies (
+    owner_id INTEGER NOT NULL,
+    task_id INTEGER NOT NULL,
+    blocker_id INTEGER NOT NULL CHECK (blocker_id <> task_id),
+    PRIMARY KEY (owner_id, task_id, blocker_id),
+    FOREIGN KEY (owner_id, task_id) REFERENCES tasks (owner_id, id) ON DELETE CASCADE,
+    FOREIGN KEY (owner_id, blocker_id) REFERENCES tasks (owner_id, id) ON DELETE CASCADE
+);
+
+CREATE INDEX task_dependencies_blocker_id_idx ON task_dependencies (owner_id, blocker_id);
+
+CREATE TABLE task_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    owner_id INTEGER NOT NULL,
+    task_id INTEGER NOT NULL,
+    revision INTEGER NOT NULL,
+    operation TEXT NOT NULL CHECK (operation IN ('create', 'update', 'delete', 'restore', 'revert')),
+    actor_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    snapshot JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    UNIQUE (owner_id, task_id, revision),
+    FOREIGN KEY (owner_id, task_id) REFERENCES tasks (owner_id, id) ON DELETE CASCADE
+);
+
+CREATE TABLE task_comments (
     id SERIAL PRIMARY KEY,
-    login TEXT UNIQUE NOT NULL,
-    hash TEXT NOT NULL,
+    owner_id INTEGER NOT NULL,
+    task_id INTEGER NOT NULL,
+    author_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    edited_at TIMESTAMP WITH TIME ZONE,
+    FOREIGN KEY (owner_id, task_id) REFERENCES tasks (owner_id, id) ON DELETE CASCADE
+);
+
+CREATE INDEX task_comments_task_idx ON task_comments (owner_id, task_id, id);
+
+CREATE TABLE task_attachments (
+    id SERIAL PRIMARY KEY,
+    owner_id INTEGER NOT NULL,
+    task_id INTEGER NOT NULL,
+    uploader_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    filename TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size BIGINT NOT NULL,
+    blob_key TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    FOREIGN KEY (owner_id, task_id) REFERENCES tasks (owner_id, id) ON DELETE CASCADE
+);
+
+CREATE INDEX task_attachments_task_idx ON task_attachments (owner_id, task_id, id);
+
+CREATE TABLE tags (
+    id SERIAL PRIMARY KEY,
+    owner_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    UNIQUE (owner_id, name)
+);
+
+CREATE TABLE task_tags (
+    owner_id INTEGER NOT NULL,
+    task_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (owner_id, task_id, tag_id),
+    FOREIGN KEY (owner_id, task_id) REFERENCES tasks (owner_id, id) ON DELETE CASCADE
+);
+
+CREATE INDEX task_tags_tag_id_idx ON task_tags (tag_id);
+
+CREATE TABLE refresh_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    family_id UUID NOT NULL DEFAULT gen_random_uuid(),
+    token_hash TEXT UNIQUE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
-);
\ No newline at end of file
+);
+
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+
+CREATE TABLE api_keys (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash TEXT UNIQUE NOT NULL,
+    scopes TEXT[] NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+CREATE INDEX api_keys_user_id_idx ON api_keys (user_id);
+
+CREATE TABLE recovery_codes (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (user_id, code_hash)
+);
+
+CREATE TABLE password_reset_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash TEXT UNIQUE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
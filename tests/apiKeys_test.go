@@ -0,0 +1,215 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"restapi/auth"
+	"restapi/cache"
+	db "restapi/db"
+	"restapi/handler"
+	"restapi/tests/mocks"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCreateAPIKeyHandler(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	h := &handler.Handler{DB: mockDB, Cache: &mocks.MockTaskCache{}}
+
+	tests := []struct {
+		name           string
+		claims         *auth.Claims
+		body           string
+		expectedStatus int
+	}{
+		{
+			name:           "Succesfully create API key",
+			claims:         &auth.Claims{UserID: testUserID, Role: auth.RoleMember},
+			body:           `{"name": "ci", "scopes": ["tasks:read"], "expires_at": "2100-01-01T00:00:00Z"}`,
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "Scope not available for role",
+			claims:         &auth.Claims{UserID: testUserID, Role: auth.RoleMember},
+			body:           `{"name": "ci", "scopes": ["users:manage"]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Missing scopes",
+			claims:         &auth.Claims{UserID: testUserID, Role: auth.RoleMember},
+			body:           `{"name": "ci"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Expiry in the past",
+			claims:         &auth.Claims{UserID: testUserID, Role: auth.RoleMember},
+			body:           `{"name": "ci", "scopes": ["tasks:read"], "expires_at": "2000-01-01T00:00:00Z"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "API key cannot create API keys",
+			claims:         &auth.Claims{UserID: testUserID, Role: auth.RoleMember, Scopes: []string{"tasks:write"}},
+			body:           `{"name": "ci", "scopes": ["tasks:read"]}`,
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB.ExpectedCalls = nil
+			mockDB.On("AddAPIKey", mock.AnythingOfType("*db.APIKey"), mock.AnythingOfType("string")).Return(nil)
+
+			req, err := http.NewRequest("POST", "/api-keys", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(handler.WithClaims(req.Context(), tt.claims))
+
+			rr := httptest.NewRecorder()
+			h.CreateAPIKeyHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+
+			if tt.expectedStatus == http.StatusCreated {
+				var response struct {
+					Key    string `json:"key"`
+					Prefix string `json:"prefix"`
+				}
+				if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+					t.Fatal(err)
+				}
+				if !auth.IsAPIKey(response.Key) || !strings.HasPrefix(response.Key, response.Prefix) {
+					t.Errorf("Unexpected API key %q with prefix %q", response.Key, response.Prefix)
+				}
+				mockDB.AssertCalled(t, "AddAPIKey", mock.Anything, auth.HashAPIKey(response.Key))
+			}
+		})
+	}
+}
+
+func TestDeleteAPIKeyHandler(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	h := &handler.Handler{DB: mockDB, Cache: &mocks.MockTaskCache{}}
+
+	tests := []struct {
+		name           string
+		keyID          string
+		dbError        error
+		expectedStatus int
+	}{
+		{name: "Succesfully revoke API key", keyID: "1", expectedStatus: http.StatusNoContent},
+		{name: "API key not found", keyID: "100", dbError: db.ErrAPIKeyNotFound, expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB.ExpectedCalls = nil
+			mockDB.On("RevokeAPIKey", testUserID, mock.AnythingOfType("int")).Return(tt.dbError)
+
+			req, err := http.NewRequest("DELETE", "/api-keys/"+tt.keyID, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+			req = mux.SetURLVars(req, map[string]string{"id": tt.keyID})
+
+			rr := httptest.NewRecorder()
+			h.DeleteAPIKeyHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestAuthorizationMiddlewareWithAPIKey(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	h := &handler.Handler{DB: mockDB, Cache: &mocks.MockTaskCache{}, Revoked: cache.NewMemoryRevocationList()}
+
+	readKey, _ := auth.GenerateAPIKey()
+	expiredKey, _ := auth.GenerateAPIKey()
+	unknownKey, _ := auth.GenerateAPIKey()
+
+	mockDB.On("AuthenticateAPIKey", auth.HashAPIKey(readKey)).
+		Return(&db.APIKey{ID: 1, UserID: testUserID, Scopes: []string{"tasks:read"}}, auth.RoleMember, nil)
+	mockDB.On("AuthenticateAPIKey", auth.HashAPIKey(expiredKey)).
+		Return((*db.APIKey)(nil), "", db.ErrAPIKeyExpired)
+	mockDB.On("AuthenticateAPIKey", auth.HashAPIKey(unknownKey)).
+		Return((*db.APIKey)(nil), "", db.ErrAPIKeyNotFound)
+
+	tests := []struct {
+		name           string
+		header         string
+		value          string
+		permission     auth.Permission
+		expectedStatus int
+	}{
+		{
+			name:           "Key in X-API-Key header",
+			header:         "X-API-Key",
+			value:          readKey,
+			permission:     auth.PermTasksRead,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Key as bearer token",
+			header:         "Authorization",
+			value:          "Bearer " + readKey,
+			permission:     auth.PermTasksRead,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Key without required scope",
+			header:         "X-API-Key",
+			value:          readKey,
+			permission:     auth.PermTasksWrite,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "Expired key",
+			header:         "X-API-Key",
+			value:          expiredKey,
+			permission:     auth.PermTasksRead,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Unknown key",
+			header:         "Authorization",
+			value:          "Bearer " + unknownKey,
+			permission:     auth.PermTasksRead,
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotUserID int
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotUserID, _ = handler.UserIDFromContext(r.Context())
+			})
+
+			req, err := http.NewRequest("GET", "/tasks", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set(tt.header, tt.value)
+
+			rr := httptest.NewRecorder()
+			h.AuthorizationMiddleware(h.RequirePermission(tt.permission)(next)).ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if tt.expectedStatus == http.StatusOK && gotUserID != testUserID {
+				t.Errorf("Expected user %d in context, got %d", testUserID, gotUserID)
+			}
+		})
+	}
+}
@@ -0,0 +1,338 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	bt "restapi/basic_types"
+	"restapi/blob"
+	db "restapi/db"
+	"restapi/handler"
+	"restapi/tests/mocks"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/mock"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// fakeS3 is a minimal in-memory stand-in for an S3-compatible service with
+// path-style addressing.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=access/") || r.Header.Get("X-Amz-Date") == "" {
+		http.Error(w, "AccessDenied", http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case "PUT":
+		body, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = body
+	case "GET", "HEAD":
+		object, ok := f.objects[r.URL.Path]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(object))
+	case "DELETE":
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func testBlobStore(t *testing.T, store blob.BlobStore) {
+	content := []byte("0123456789abcdef")
+	if err := store.Put("1/2/key", bytes.NewReader(content), int64(len(content))); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := store.Open("1/2/key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Seek(10, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "abcdef" {
+		t.Errorf("Expected %q after seeking, got %q", "abcdef", got)
+	}
+
+	if err := store.Delete("1/2/key"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Open("1/2/key"); !errors.Is(err, blob.ErrBlobNotFound) {
+		t.Errorf("Expected ErrBlobNotFound after delete, got %v", err)
+	}
+	if err := store.Delete("1/2/key"); err != nil {
+		t.Errorf("Expected deleting a missing blob to succeed, got %v", err)
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	store := blob.NewLocalStore(t.TempDir())
+	testBlobStore(t, store)
+
+	if err := store.Put("../escape", strings.NewReader("x"), 1); !errors.Is(err, blob.ErrInvalidBlobKey) {
+		t.Errorf("Expected ErrInvalidBlobKey, got %v", err)
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	fake := &fakeS3{objects: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	testBlobStore(t, blob.NewS3Store(server.URL, "attachments", "us-east-1", "access", "secret"))
+
+	store := blob.NewS3Store(server.URL, "attachments", "us-east-1", "intruder", "secret")
+	if err := store.Put("1/2/key", strings.NewReader("x"), 1); err == nil {
+		t.Errorf("Expected the stand-in to reject unknown credentials")
+	}
+}
+
+func TestNewBlobStoreFromEnv(t *testing.T) {
+	t.Setenv("BLOB_STORE", "s3")
+	t.Setenv("S3_ENDPOINT", "http://localhost:9000")
+	t.Setenv("S3_BUCKET", "")
+	if _, err := blob.NewBlobStoreFromEnv(); err == nil {
+		t.Errorf("Expected a missing bucket to be rejected")
+	}
+
+	t.Setenv("BLOB_STORE", "ftp")
+	if _, err := blob.NewBlobStoreFromEnv(); err == nil {
+		t.Errorf("Expected an unknown blob store to be rejected")
+	}
+}
+
+func multipartUpload(t *testing.T, filename string, content []byte) (*bytes.Buffer, string) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if filename != "" {
+		part, err := mw.CreateFormFile("file", filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(content)
+	}
+	mw.Close()
+	return &body, mw.FormDataContentType()
+}
+
+func TestUploadAttachmentHandler(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	dir := t.TempDir()
+	h := &handler.Handler{DB: mockDB, Cache: &mocks.MockTaskCache{}, Blobs: blob.NewLocalStore(dir)}
+
+	mockDB.On("AddAttachment", mock.MatchedBy(func(a *bt.Attachment) bool {
+		return a.TaskID == 1 && a.OwnerID == testUserID && *a.UploaderID == testUserID &&
+			a.Filename == "logo.png" && a.ContentType == "image/png" && a.Size == int64(len(pngHeader))
+	})).Run(func(args mock.Arguments) {
+		args.Get(0).(*bt.Attachment).ID = 5
+	}).Return(nil)
+	mockDB.On("AddAttachment", mock.MatchedBy(func(a *bt.Attachment) bool { return a.TaskID == 2 })).
+		Return(db.ErrTaskNotFound)
+
+	tests := []struct {
+		name           string
+		id             string
+		filename       string
+		content        []byte
+		expectedStatus int
+	}{
+		{name: "Succesfully upload attachment", id: "1", filename: "logo.png", content: pngHeader, expectedStatus: http.StatusCreated},
+		{name: "Task not found or in trash", id: "2", filename: "logo.png", content: pngHeader, expectedStatus: http.StatusNotFound},
+		{name: "Missing file", id: "1", expectedStatus: http.StatusBadRequest},
+		{name: "Empty file", id: "1", filename: "empty.txt", expectedStatus: http.StatusBadRequest},
+		{name: "Too large", id: "1", filename: "big.bin", content: make([]byte, bt.MaxAttachmentSize+1), expectedStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, contentType := multipartUpload(t, tt.filename, tt.content)
+			req, err := http.NewRequest("POST", "/tasks/"+tt.id+"/attachments", body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", contentType)
+			req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+			req = mux.SetURLVars(req, map[string]string{"id": tt.id})
+
+			rr := httptest.NewRecorder()
+			h.UploadAttachmentHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body)
+			}
+			if tt.expectedStatus == http.StatusCreated && rr.Header().Get("Location") != "/tasks/1/attachments/5" {
+				t.Errorf("Expected Location /tasks/1/attachments/5, got %q", rr.Header().Get("Location"))
+			}
+		})
+	}
+
+	blobs, err := filepath.Glob(filepath.Join(dir, "*", "*", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) != 1 || !strings.HasPrefix(blobs[0], filepath.Join(dir, strconv.Itoa(testUserID), "1")) {
+		t.Errorf("Expected only the accepted upload to be stored, got %v", blobs)
+	}
+}
+
+func TestDownloadAttachmentHandler(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	store := blob.NewLocalStore(t.TempDir())
+	h := &handler.Handler{DB: mockDB, Cache: &mocks.MockTaskCache{}, Blobs: store}
+
+	content := []byte("hello, attachment")
+	if err := store.Put("1/1/abc", bytes.NewReader(content), int64(len(content))); err != nil {
+		t.Fatal(err)
+	}
+
+	mockDB.On("GetAttachment", testUserID, 1, 5).Return(&bt.Attachment{
+		ID: 5, TaskID: 1, Filename: "notes.txt", ContentType: "text/plain; charset=utf-8",
+		Size: int64(len(content)), BlobKey: "1/1/abc",
+	}, nil)
+	mockDB.On("GetAttachment", testUserID, 1, 6).Return((*bt.Attachment)(nil), db.ErrAttachmentNotFound)
+
+	tests := []struct {
+		name           string
+		attachmentID   string
+		rangeHeader    string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "Download whole file", attachmentID: "5", expectedStatus: http.StatusOK, expectedBody: "hello, attachment"},
+		{name: "Download range", attachmentID: "5", rangeHeader: "bytes=7-", expectedStatus: http.StatusPartialContent, expectedBody: "attachment"},
+		{name: "Unsatisfiable range", attachmentID: "5", rangeHeader: "bytes=100-", expectedStatus: http.StatusRequestedRangeNotSatisfiable},
+		{name: "Attachment not found", attachmentID: "6", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/tasks/1/attachments/"+tt.attachmentID, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.rangeHeader != "" {
+				req.Header.Set("Range", tt.rangeHeader)
+			}
+			req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+			req = mux.SetURLVars(req, map[string]string{"id": "1", "attachmentID": tt.attachmentID})
+
+			rr := httptest.NewRecorder()
+			h.DownloadAttachmentHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if tt.expectedBody == "" {
+				return
+			}
+			if rr.Body.String() != tt.expectedBody {
+				t.Errorf("Expected body %q, got %q", tt.expectedBody, rr.Body.String())
+			}
+			if rr.Header().Get("Content-Disposition") != `attachment; filename=notes.txt` {
+				t.Errorf("Unexpected Content-Disposition %q", rr.Header().Get("Content-Disposition"))
+			}
+			if rr.Header().Get("Content-Type") != "text/plain; charset=utf-8" {
+				t.Errorf("Unexpected Content-Type %q", rr.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestGetAttachmentsHandler(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	h := &handler.Handler{DB: mockDB, Cache: &mocks.MockTaskCache{}}
+
+	mockDB.On("GetAttachments", testUserID, 1).Return([]bt.Attachment{{ID: 5, TaskID: 1, Filename: "notes.txt", BlobKey: "1/1/abc"}}, nil)
+
+	req, _ := http.NewRequest("GET", "/tasks/1/attachments", nil)
+	req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+	rr := httptest.NewRecorder()
+	h.GetAttachmentsHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	var got []map[string]interface{}
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0]["filename"] != "notes.txt" {
+		t.Errorf("Unexpected attachments %v", got)
+	}
+	if _, ok := got[0]["blob_key"]; ok {
+		t.Errorf("Expected the blob key not to be exposed")
+	}
+}
+
+func TestDeleteAttachmentHandler(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	dir := t.TempDir()
+	store := blob.NewLocalStore(dir)
+	h := &handler.Handler{DB: mockDB, Cache: &mocks.MockTaskCache{}, Blobs: store}
+
+	if err := store.Put("1/1/abc", strings.NewReader("x"), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	mockDB.On("DeleteAttachment", testUserID, 1, 5).Return("1/1/abc", nil)
+	mockDB.On("DeleteAttachment", testUserID, 1, 6).Return("", db.ErrAttachmentNotFound)
+
+	tests := []struct {
+		name           string
+		attachmentID   string
+		expectedStatus int
+	}{
+		{name: "Succesfully delete attachment", attachmentID: "5", expectedStatus: http.StatusNoContent},
+		{name: "Attachment not found", attachmentID: "6", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("DELETE", "/tasks/1/attachments/"+tt.attachmentID, nil)
+			req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+			req = mux.SetURLVars(req, map[string]string{"id": "1", "attachmentID": tt.attachmentID})
+
+			rr := httptest.NewRecorder()
+			h.DeleteAttachmentHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+		})
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "1", "1", "abc")); !os.IsNotExist(err) {
+		t.Errorf("Expected the blob to be deleted, got %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"restapi/auth"
+	"restapi/cache"
+	"restapi/handler"
+	"restapi/tests/mocks"
+	"testing"
+	"time"
+)
+
+func TestAuthorizationMiddleware(t *testing.T) {
+	revoked := cache.NewMemoryRevocationList()
+	h := &handler.Handler{DB: &mocks.MockTaskStore{}, Cache: &mocks.MockTaskCache{}, Revoked: revoked}
+
+	validToken, _ := auth.GenerateToken(testUserID, auth.RoleMember)
+	revokedToken, _ := auth.GenerateToken(testUserID, auth.RoleMember)
+	revokedClaims, _ := auth.ValidateToken(revokedToken)
+	revoked.Revoke(revokedClaims.ID, time.Minute)
+
+	tests := []struct {
+		name           string
+		authHeader     string
+		expectedStatus int
+	}{
+		{
+			name:           "Valid token",
+			authHeader:     "Bearer " + validToken,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Missing token",
+			authHeader:     "",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Invalid token format",
+			authHeader:     "Token " + validToken,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Malformed token",
+			authHeader:     "Bearer not.a.jwt",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Revoked token",
+			authHeader:     "Bearer " + revokedToken,
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotUserID int
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotUserID, _ = handler.UserIDFromContext(r.Context())
+			})
+
+			req, err := http.NewRequest("GET", "/tasks", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+
+			rr := httptest.NewRecorder()
+			h.AuthorizationMiddleware(next).ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+
+			if tt.expectedStatus == http.StatusOK && gotUserID != testUserID {
+				t.Errorf("Expected user %d in context, got %d", testUserID, gotUserID)
+			}
+		})
+	}
+}
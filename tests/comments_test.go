@@ -0,0 +1,201 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	bt "restapi/basic_types"
+	db "restapi/db"
+	"restapi/handler"
+	"restapi/tests/mocks"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCreateCommentHandler(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	h := &handler.Handler{DB: mockDB, Cache: &mocks.MockTaskCache{}}
+
+	mockDB.On("AddComment", mock.MatchedBy(func(c *bt.Comment) bool {
+		return c.TaskID == 1 && c.OwnerID == testUserID && *c.AuthorID == testUserID && c.Body == "Looks good"
+	})).Run(func(args mock.Arguments) {
+		args.Get(0).(*bt.Comment).ID = 3
+	}).Return(nil)
+	mockDB.On("AddComment", mock.MatchedBy(func(c *bt.Comment) bool { return c.TaskID == 2 })).Return(db.ErrTaskNotFound)
+
+	tests := []struct {
+		name           string
+		id             string
+		body           string
+		expectedStatus int
+	}{
+		{name: "Succesfully add comment", id: "1", body: `{"body": " Looks good ", "author_id": 99}`, expectedStatus: http.StatusCreated},
+		{name: "Task not found or in trash", id: "2", body: `{"body": "Hello"}`, expectedStatus: http.StatusNotFound},
+		{name: "Empty body", id: "1", body: `{"body": "  "}`, expectedStatus: http.StatusBadRequest},
+		{name: "Too long", id: "1", body: `{"body": "` + strings.Repeat("a", bt.MaxCommentLength+1) + `"}`, expectedStatus: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", "/tasks/"+tt.id+"/comments", bytes.NewBufferString(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+			req = mux.SetURLVars(req, map[string]string{"id": tt.id})
+
+			rr := httptest.NewRecorder()
+			h.CreateCommentHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if tt.expectedStatus == http.StatusCreated && rr.Header().Get("Location") != "/tasks/1/comments/3" {
+				t.Errorf("Expected Location /tasks/1/comments/3, got %q", rr.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestGetCommentsHandler(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	h := &handler.Handler{DB: mockDB, Cache: &mocks.MockTaskCache{}}
+
+	author := testUserID
+	mockDB.On("GetComments", testUserID, 1, 0, 2).
+		Return([]bt.Comment{{ID: 1, TaskID: 1, AuthorID: &author, Body: "a"}, {ID: 2, TaskID: 1, AuthorID: &author, Body: "b"}}, 2, nil)
+	mockDB.On("GetComments", testUserID, 1, 2, db.MaxTaskPageSize).
+		Return([]bt.Comment{{ID: 4, TaskID: 1, Body: "c"}}, 0, nil)
+	mockDB.On("GetComments", testUserID, 2, 0, db.DefaultTaskPageSize).
+		Return([]bt.Comment(nil), 0, db.ErrTaskNotFound)
+
+	tests := []struct {
+		name           string
+		id             string
+		rawQuery       string
+		expectedStatus int
+		expectedCount  int
+		expectedNext   string
+	}{
+		{name: "First page", id: "1", rawQuery: "limit=2", expectedStatus: http.StatusOK, expectedCount: 2, expectedNext: "2"},
+		{name: "Last page with capped limit", id: "1", rawQuery: "limit=1000&cursor=2", expectedStatus: http.StatusOK, expectedCount: 1},
+		{name: "Task not found", id: "2", expectedStatus: http.StatusNotFound},
+		{name: "Malformed cursor", id: "1", rawQuery: "cursor=abc", expectedStatus: http.StatusBadRequest},
+		{name: "Invalid limit", id: "1", rawQuery: "limit=-1", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/tasks/"+tt.id+"/comments?"+tt.rawQuery, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+			req = mux.SetURLVars(req, map[string]string{"id": tt.id})
+
+			rr := httptest.NewRecorder()
+			h.GetCommentsHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var page struct {
+				Comments   []bt.Comment `json:"comments"`
+				NextCursor string       `json:"next_cursor"`
+			}
+			if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+				t.Fatal(err)
+			}
+			if len(page.Comments) != tt.expectedCount || page.NextCursor != tt.expectedNext {
+				t.Errorf("Expected %d comments and cursor %q, got %d and %q",
+					tt.expectedCount, tt.expectedNext, len(page.Comments), page.NextCursor)
+			}
+		})
+	}
+}
+
+func TestUpdateCommentHandler(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	h := &handler.Handler{DB: mockDB, Cache: &mocks.MockTaskCache{}}
+
+	author := testUserID
+	mockDB.On("UpdateComment", testUserID, 1, 3, testUserID, "Fixed typo").
+		Return(&bt.Comment{ID: 3, TaskID: 1, AuthorID: &author, Body: "Fixed typo"}, nil)
+	mockDB.On("UpdateComment", testUserID, 1, 4, testUserID, "Fixed typo").
+		Return((*bt.Comment)(nil), db.ErrNotCommentAuthor)
+	mockDB.On("UpdateComment", testUserID, 1, 5, testUserID, "Fixed typo").
+		Return((*bt.Comment)(nil), db.ErrCommentNotFound)
+
+	tests := []struct {
+		name           string
+		commentID      string
+		expectedStatus int
+	}{
+		{name: "Succesfully edit comment", commentID: "3", expectedStatus: http.StatusOK},
+		{name: "Comment of another user", commentID: "4", expectedStatus: http.StatusForbidden},
+		{name: "Comment not found", commentID: "5", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("PUT", "/tasks/1/comments/"+tt.commentID, bytes.NewBufferString(`{"body": "Fixed typo"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+			req = mux.SetURLVars(req, map[string]string{"id": "1", "commentID": tt.commentID})
+
+			rr := httptest.NewRecorder()
+			h.UpdateCommentHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestDeleteCommentHandler(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	h := &handler.Handler{DB: mockDB, Cache: &mocks.MockTaskCache{}}
+
+	mockDB.On("DeleteComment", testUserID, 1, 3).Return(nil)
+	mockDB.On("DeleteComment", testUserID, 1, 5).Return(db.ErrCommentNotFound)
+
+	tests := []struct {
+		name           string
+		vars           map[string]string
+		expectedStatus int
+	}{
+		{name: "Owner deletes comment", vars: map[string]string{"id": "1", "commentID": "3"}, expectedStatus: http.StatusNoContent},
+		{name: "Comment not found", vars: map[string]string{"id": "1", "commentID": "5"}, expectedStatus: http.StatusNotFound},
+		{name: "Another user's task without permission", vars: map[string]string{"userID": "7", "id": "1", "commentID": "3"},
+			expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("DELETE", "/tasks/1/comments/"+tt.vars["commentID"], nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+			req = mux.SetURLVars(req, tt.vars)
+
+			rr := httptest.NewRecorder()
+			h.DeleteCommentHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+		})
+	}
+}
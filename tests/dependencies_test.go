@@ -0,0 +1,217 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	bt "restapi/basic_types"
+	db "restapi/db"
+	"restapi/handler"
+	"restapi/tests/mocks"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func TestBuildTaskTree(t *testing.T) {
+	nodes := []db.TaskNode{
+		{Task: bt.Task{ID: 1}},
+		{Task: bt.Task{ID: 2, ParentID: intPtr(1)}, BlockedBy: []int{3}},
+		{Task: bt.Task{ID: 3, ParentID: intPtr(1)}},
+		{Task: bt.Task{ID: 4, ParentID: intPtr(2)}},
+	}
+
+	tree := db.BuildTaskTree(nodes, 1)
+	if tree == nil {
+		t.Fatal("Expected a tree")
+	}
+	if len(tree.Children) != 2 || tree.Children[0].ID != 2 || tree.Children[1].ID != 3 {
+		t.Fatalf("Unexpected children of the root: %+v", tree.Children)
+	}
+	if len(tree.Children[0].Children) != 1 || tree.Children[0].Children[0].ID != 4 {
+		t.Errorf("Unexpected children of task 2: %+v", tree.Children[0].Children)
+	}
+	if len(tree.Children[0].BlockedBy) != 1 || tree.Children[0].BlockedBy[0] != 3 {
+		t.Errorf("Unexpected blockers of task 2: %v", tree.Children[0].BlockedBy)
+	}
+	if tree.Children[1].Children == nil {
+		t.Errorf("Expected leaf children to be an empty list")
+	}
+
+	if db.BuildTaskTree(nodes, 9) != nil {
+		t.Errorf("Expected no tree for a missing root")
+	}
+}
+
+func TestSetTaskParentHandlers(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	mockCache := &mocks.MockTaskCache{}
+	h := &handler.Handler{DB: mockDB, Cache: mockCache}
+
+	mockDB.On("SetTaskParent", testUserID, 2, intPtr(1), testUserID).Return(&bt.Task{ID: 2, ParentID: intPtr(1), Version: 2}, nil)
+	mockDB.On("SetTaskParent", testUserID, 1, intPtr(2), testUserID).Return((*bt.Task)(nil), db.ErrDependencyCycle)
+	mockDB.On("SetTaskParent", testUserID, 3, intPtr(9), testUserID).Return((*bt.Task)(nil), db.ErrTaskNotFound)
+	mockDB.On("SetTaskParent", testUserID, 2, (*int)(nil), testUserID).Return(&bt.Task{ID: 2, Version: 3}, nil)
+	mockCache.On("Delete", testUserID, 2).Return(nil)
+
+	tests := []struct {
+		name           string
+		id             string
+		parentID       string
+		expectedStatus int
+		expectedETag   string
+	}{
+		{name: "Succesfully set parent", id: "2", parentID: "1", expectedStatus: http.StatusOK, expectedETag: `"2"`},
+		{name: "Cycle", id: "1", parentID: "2", expectedStatus: http.StatusConflict},
+		{name: "Parent not found", id: "3", parentID: "9", expectedStatus: http.StatusNotFound},
+		{name: "Succesfully remove parent", id: "2", expectedStatus: http.StatusOK, expectedETag: `"3"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vars := map[string]string{"id": tt.id}
+			method, handle := "DELETE", h.RemoveTaskParentHandler
+			if tt.parentID != "" {
+				vars["parentID"] = tt.parentID
+				method, handle = "PUT", h.SetTaskParentHandler
+			}
+
+			req, err := http.NewRequest(method, "/tasks/"+tt.id+"/parent/"+tt.parentID, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+			req = mux.SetURLVars(req, vars)
+
+			rr := httptest.NewRecorder()
+			handle(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if etag := rr.Header().Get("ETag"); etag != tt.expectedETag {
+				t.Errorf("Expected ETag %q, got %q", tt.expectedETag, etag)
+			}
+		})
+	}
+}
+
+func TestTaskBlockerHandlers(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	h := &handler.Handler{DB: mockDB, Cache: &mocks.MockTaskCache{}}
+
+	mockDB.On("AddTaskBlocker", testUserID, 1, 2).Return(nil)
+	mockDB.On("AddTaskBlocker", testUserID, 2, 1).Return(db.ErrDependencyCycle)
+	mockDB.On("RemoveTaskBlocker", testUserID, 1, 2).Return(nil)
+	mockDB.On("RemoveTaskBlocker", testUserID, 1, 3).Return(db.ErrDependencyNotFound)
+
+	tests := []struct {
+		name           string
+		remove         bool
+		id             string
+		blockerID      string
+		expectedStatus int
+	}{
+		{name: "Succesfully add blocker", id: "1", blockerID: "2", expectedStatus: http.StatusNoContent},
+		{name: "Cycle", id: "2", blockerID: "1", expectedStatus: http.StatusConflict},
+		{name: "Succesfully remove blocker", remove: true, id: "1", blockerID: "2", expectedStatus: http.StatusNoContent},
+		{name: "Missing dependency", remove: true, id: "1", blockerID: "3", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method, handle := "PUT", h.AddTaskBlockerHandler
+			if tt.remove {
+				method, handle = "DELETE", h.RemoveTaskBlockerHandler
+			}
+
+			req, err := http.NewRequest(method, "/tasks/"+tt.id+"/blockers/"+tt.blockerID, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+			req = mux.SetURLVars(req, map[string]string{"id": tt.id, "blockerID": tt.blockerID})
+
+			rr := httptest.NewRecorder()
+			handle(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestGetTaskTreeHandler(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	h := &handler.Handler{DB: mockDB, Cache: &mocks.MockTaskCache{}}
+
+	tree := &db.TaskNode{
+		Task:      bt.Task{ID: 1, Name: "Release"},
+		BlockedBy: []int{},
+		Children:  []db.TaskNode{{Task: bt.Task{ID: 2, Name: "Changelog", ParentID: intPtr(1)}, BlockedBy: []int{3}, Children: []db.TaskNode{}}},
+	}
+	mockDB.On("GetTaskTree", testUserID, 1).Return(tree, nil)
+	mockDB.On("GetTaskTree", testUserID, 2).Return((*db.TaskNode)(nil), db.ErrTaskNotFound)
+
+	req, _ := http.NewRequest("GET", "/tasks/1/tree", nil)
+	req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+	rr := httptest.NewRecorder()
+	h.GetTaskTreeHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	var got struct {
+		ID       int `json:"id"`
+		Children []struct {
+			ID        int   `json:"id"`
+			ParentID  int   `json:"parent_id"`
+			BlockedBy []int `json:"blocked_by"`
+		} `json:"children"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != 1 || len(got.Children) != 1 || got.Children[0].ParentID != 1 || got.Children[0].BlockedBy[0] != 3 {
+		t.Errorf("Unexpected tree %+v", got)
+	}
+
+	req, _ = http.NewRequest("GET", "/tasks/2/tree", nil)
+	req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+	req = mux.SetURLVars(req, map[string]string{"id": "2"})
+
+	rr = httptest.NewRecorder()
+	h.GetTaskTreeHandler(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestCompletingBlockedTask(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	h := &handler.Handler{DB: mockDB, Cache: &mocks.MockTaskCache{}}
+
+	mockDB.On("UpdateTask", &bt.Task{ID: 1, OwnerID: testUserID, Name: "Release", Description: "v2", Status: bt.StatusDone}, testUserID).
+		Return((*bt.Task)(nil), db.ErrTaskBlocked)
+
+	body := `{"name": "Release", "description": "v2", "status": "done", "parent_id": 5}`
+	req, _ := http.NewRequest("PUT", "/tasks/1", bytes.NewBufferString(body))
+	req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+	rr := httptest.NewRecorder()
+	h.UpdateTaskHandler(rr, req)
+
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected status %d, got %d", http.StatusConflict, rr.Code)
+	}
+}
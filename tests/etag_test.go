@@ -0,0 +1,129 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	bt "restapi/basic_types"
+	db "restapi/db"
+	"restapi/handler"
+	"restapi/tests/mocks"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetTaskIfNoneMatch(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	mockCache := &mocks.MockTaskCache{}
+	h := &handler.Handler{DB: mockDB, Cache: mockCache}
+
+	cached := &bt.Task{ID: 1, OwnerID: testUserID, Name: "Test Task", Description: "Test Description", Version: 3}
+	mockCache.On("Get", testUserID, 1).Return(cached, nil)
+
+	tests := []struct {
+		name           string
+		ifNoneMatch    string
+		expectedStatus int
+	}{
+		{name: "No condition", expectedStatus: http.StatusOK},
+		{name: "Current version from cache", ifNoneMatch: `"3"`, expectedStatus: http.StatusNotModified},
+		{name: "Weak comparison", ifNoneMatch: `"1", W/"3"`, expectedStatus: http.StatusNotModified},
+		{name: "Any version", ifNoneMatch: "*", expectedStatus: http.StatusNotModified},
+		{name: "Outdated version", ifNoneMatch: `"2"`, expectedStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/tasks/1", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+			req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+			rr := httptest.NewRecorder()
+			h.GetTaskHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if etag := rr.Header().Get("ETag"); etag != `"3"` {
+				t.Errorf(`Expected ETag "3", got %s`, etag)
+			}
+			if tt.expectedStatus == http.StatusNotModified && rr.Body.Len() != 0 {
+				t.Errorf("Expected empty body for 304, got %q", rr.Body.String())
+			}
+		})
+	}
+
+	mockDB.AssertNotCalled(t, "GetTask", mock.Anything, mock.Anything)
+}
+
+func TestTaskWritesIfMatch(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	mockCache := &mocks.MockTaskCache{}
+	h := &handler.Handler{DB: mockDB, Cache: mockCache}
+
+	mockCache.On("Delete", testUserID, 1).Return(nil)
+	mockDB.On("UpdateTask", mock.MatchedBy(func(task *bt.Task) bool { return task.Version == 3 || task.Version == -1 }), testUserID).
+		Return((*bt.Task)(nil), db.ErrVersionMismatch)
+	mockDB.On("UpdateTask", mock.MatchedBy(func(task *bt.Task) bool { return task.Version == 4 }), testUserID).
+		Return(&bt.Task{ID: 1, Name: "Test Task", Description: "Test Description", Version: 5}, nil)
+	mockDB.On("DeleteTask", testUserID, 1, 3, testUserID).Return(db.ErrVersionMismatch)
+	mockDB.On("DeleteTask", testUserID, 1, 4, testUserID).Return(nil)
+	mockDB.On("DeleteTask", testUserID, 1, -1, testUserID).Return(db.ErrVersionMismatch)
+	mockDB.On("GetTask", testUserID, 1).Return(&bt.Task{ID: 1, Name: "Test Task", Version: 4}, nil)
+
+	tests := []struct {
+		name           string
+		method         string
+		ifMatch        string
+		expectedStatus int
+		expectedETag   string
+	}{
+		{name: "Update with current version", method: "PUT", ifMatch: `"4"`, expectedStatus: http.StatusOK, expectedETag: `"5"`},
+		{name: "Update with stale version", method: "PUT", ifMatch: `"3"`, expectedStatus: http.StatusPreconditionFailed},
+		{name: "Update with several tags including the current one", method: "PUT", ifMatch: `"3", "4"`, expectedStatus: http.StatusOK, expectedETag: `"5"`},
+		{name: "Update with several stale tags", method: "PUT", ifMatch: `"2", "3"`, expectedStatus: http.StatusPreconditionFailed},
+		{name: "Update with a weak tag", method: "PUT", ifMatch: `W/"4"`, expectedStatus: http.StatusPreconditionFailed},
+		{name: "Update with a malformed tag in a list", method: "PUT", ifMatch: `"3", 4`, expectedStatus: http.StatusBadRequest},
+		{name: "Delete with current version", method: "DELETE", ifMatch: `"4"`, expectedStatus: http.StatusNoContent},
+		{name: "Delete with stale version", method: "DELETE", ifMatch: `"3"`, expectedStatus: http.StatusPreconditionFailed},
+		{name: "Delete with several tags including the current one", method: "DELETE", ifMatch: `"4", "3"`, expectedStatus: http.StatusNoContent},
+		{name: "Delete with several stale tags", method: "DELETE", ifMatch: `"2", "3"`, expectedStatus: http.StatusPreconditionFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(map[string]string{"name": "Test Task", "description": "Test Description"})
+
+			req, err := http.NewRequest(tt.method, "/tasks/1", bytes.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("If-Match", tt.ifMatch)
+			req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+			req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+			rr := httptest.NewRecorder()
+			if tt.method == "PUT" {
+				h.UpdateTaskHandler(rr, req)
+			} else {
+				h.DeleteTaskHandler(rr, req)
+			}
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if etag := rr.Header().Get("ETag"); etag != tt.expectedETag {
+				t.Errorf("Expected ETag %q, got %q", tt.expectedETag, etag)
+			}
+		})
+	}
+}
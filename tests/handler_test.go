@@ -7,19 +7,24 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"restapi/auth"
 	bt "restapi/basic_types"
 	"restapi/cache"
 	db "restapi/db"
 	"restapi/handler"
 	"restapi/tests/mocks"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 
 	"github.com/stretchr/testify/mock"
 )
 
+const testUserID = 42
+
 func TestCreateTaskHandler(t *testing.T) {
 	mockDB := &mocks.MockTaskStore{}
 	mockCache := &mocks.MockTaskCache{}
@@ -28,6 +33,8 @@ func TestCreateTaskHandler(t *testing.T) {
 	type taskInfo struct {
 		Name        string `json:"name"`
 		Description string `json:"description"`
+		Priority    int    `json:"priority,omitempty"`
+		DueAt       string `json:"due_at,omitempty"`
 	}
 
 	tests := []struct {
@@ -44,6 +51,7 @@ func TestCreateTaskHandler(t *testing.T) {
 			inputInfo: taskInfo{
 				Name:        "Test Task",
 				Description: "Test Description",
+				Priority:    3,
 			},
 			mockAddTaskError: nil,
 			expectedStatus:   http.StatusCreated,
@@ -51,8 +59,29 @@ func TestCreateTaskHandler(t *testing.T) {
 				ID:          1,
 				Name:        "Test Task",
 				Description: "Test Description",
+				Priority:    3,
 			},
 		},
+		{
+			name:    "Priority out of range",
+			inputID: 1,
+			inputInfo: taskInfo{
+				Name:        "Test Task",
+				Description: "Test Description",
+				Priority:    bt.MaxPriority + 1,
+			},
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:    "Due date is not RFC 3339",
+			inputID: 1,
+			inputInfo: taskInfo{
+				Name:        "Test Task",
+				Description: "Test Description",
+				DueAt:       "tomorrow",
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
 		{
 			name:    "Task already exists",
 			inputID: 1,
@@ -82,7 +111,9 @@ func TestCreateTaskHandler(t *testing.T) {
 			id := strconv.Itoa(tt.inputID)
 
 			mockDB.ExpectedCalls = nil
-			mockDB.On("AddTask", mock.AnythingOfType("*basic_types.Task")).Return(tt.mockAddTaskError)
+			mockDB.On("AddTask", mock.MatchedBy(func(task *bt.Task) bool {
+				return task.OwnerID == testUserID
+			}), testUserID).Return(tt.mockAddTaskError)
 
 			body, _ := json.Marshal(tt.inputInfo)
 
@@ -91,6 +122,7 @@ func TestCreateTaskHandler(t *testing.T) {
 				t.Fatal(err)
 			}
 
+			req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
 			req = mux.SetURLVars(req, map[string]string{
 				"id": id,
 			})
@@ -103,11 +135,15 @@ func TestCreateTaskHandler(t *testing.T) {
 			}
 
 			if tt.expectedStatus == http.StatusCreated {
+				if location := rr.Header().Get("Location"); location != "/tasks/"+id {
+					t.Errorf("Expected Location /tasks/%s, got %q", id, location)
+				}
+
 				var responseTask bt.Task
 				if err := json.NewDecoder(rr.Body).Decode(&responseTask); err != nil {
 					t.Fatal(err)
 				}
-				if responseTask != tt.expectedResponse {
+				if !reflect.DeepEqual(responseTask, tt.expectedResponse) {
 					t.Errorf("Expected response %v, got %v", tt.expectedResponse, responseTask)
 				}
 			}
@@ -115,6 +151,44 @@ func TestCreateTaskHandler(t *testing.T) {
 	}
 }
 
+func TestCreateTaskWithGeneratedID(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	h := &handler.Handler{DB: mockDB, Cache: &mocks.MockTaskCache{}}
+
+	mockDB.On("CreateTask", mock.MatchedBy(func(task *bt.Task) bool {
+		return task.ID == 0 && task.OwnerID == testUserID
+	}), testUserID).Run(func(args mock.Arguments) {
+		args.Get(0).(*bt.Task).ID = 17
+	}).Return(nil)
+
+	body, _ := json.Marshal(map[string]interface{}{"id": 5, "name": "Test Task", "description": "Test Description"})
+
+	req, err := http.NewRequest("POST", "/tasks", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+
+	rr := httptest.NewRecorder()
+	h.CreateTaskHandler(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d", http.StatusCreated, rr.Code)
+	}
+	if location := rr.Header().Get("Location"); location != "/tasks/17" {
+		t.Errorf("Expected Location /tasks/17, got %q", location)
+	}
+
+	var responseTask bt.Task
+	if err := json.NewDecoder(rr.Body).Decode(&responseTask); err != nil {
+		t.Fatal(err)
+	}
+	if responseTask.ID != 17 {
+		t.Errorf("Expected generated ID 17, got %d", responseTask.ID)
+	}
+	mockDB.AssertNotCalled(t, "AddTask", mock.Anything)
+}
+
 func TestGetTaskHandler(t *testing.T) {
 	mockDB := &mocks.MockTaskStore{}
 	mockCache := &mocks.MockTaskCache{}
@@ -183,17 +257,18 @@ func TestGetTaskHandler(t *testing.T) {
 			id, _ := strconv.Atoi(tt.taskID)
 
 			mockCache.ExpectedCalls = nil
-			mockCache.On("Get", id).Return(tt.cachedTask, tt.cachedGetError)
+			mockCache.On("Get", testUserID, id).Return(tt.cachedTask, tt.cachedGetError)
 			mockCache.On("Set", tt.dbTask).Return(nil)
 
 			mockDB.ExpectedCalls = nil
-			mockDB.On("GetTask", id).Return(tt.dbTask, tt.dbGetError)
+			mockDB.On("GetTask", testUserID, id).Return(tt.dbTask, tt.dbGetError)
 
 			req, err := http.NewRequest("GET", "/tasks/"+tt.taskID, nil)
 			if err != nil {
 				t.Fatal(err)
 			}
 
+			req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
 			req = mux.SetURLVars(req, map[string]string{
 				"id": tt.taskID,
 			})
@@ -211,7 +286,7 @@ func TestGetTaskHandler(t *testing.T) {
 					t.Fatal(err)
 				}
 
-				if responseTask != tt.expectedResponse {
+				if !reflect.DeepEqual(responseTask, tt.expectedResponse) {
 					t.Errorf("Expected response %v, got %v", tt.expectedResponse, responseTask)
 				}
 			}
@@ -224,15 +299,21 @@ func TestGetAllTasksHandler(t *testing.T) {
 	mockCache := &mocks.MockTaskCache{}
 	h := &handler.Handler{DB: mockDB, Cache: mockCache}
 
+	nextCursor := db.EncodeTaskCursor(&db.TaskCursor{Sort: "-name", Value: "Test Task 1", ID: 1})
+
 	tests := []struct {
 		name             string
+		rawQuery         string
+		expectedQuery    db.TaskQuery
 		dbTasks          []bt.Task
+		dbNextCursor     string
 		dbGetError       error
 		expectedStatus   int
 		expectedResponse []bt.Task
 	}{
 		{
-			name: "Succesfully get all tasks",
+			name:          "Succesfully get all tasks",
+			expectedQuery: db.TaskQuery{OwnerID: testUserID, Limit: db.DefaultTaskPageSize, Sort: "id"},
 			dbTasks: []bt.Task{
 				{
 					ID:          1,
@@ -260,8 +341,102 @@ func TestGetAllTasksHandler(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:     "Filters, sort and capped limit",
+			rawQuery: "limit=1000&sort=-name&name=Task&min_id=1&max_id=9",
+			expectedQuery: db.TaskQuery{
+				OwnerID:      testUserID,
+				Limit:        db.MaxTaskPageSize,
+				Sort:         "name",
+				Desc:         true,
+				NameContains: "Task",
+				MinID:        1,
+				MaxID:        9,
+			},
+			dbTasks:          []bt.Task{{ID: 1, Name: "Test Task 1"}},
+			dbNextCursor:     nextCursor,
+			expectedStatus:   http.StatusOK,
+			expectedResponse: []bt.Task{{ID: 1, Name: "Test Task 1"}},
+		},
+		{
+			name:     "Next page",
+			rawQuery: "sort=-name&cursor=" + nextCursor,
+			expectedQuery: db.TaskQuery{
+				OwnerID: testUserID,
+				Limit:   db.DefaultTaskPageSize,
+				Sort:    "name",
+				Desc:    true,
+				After:   &db.TaskCursor{Sort: "-name", Value: "Test Task 1", ID: 1},
+			},
+			dbTasks:          []bt.Task{},
+			expectedStatus:   http.StatusOK,
+			expectedResponse: []bt.Task{},
+		},
+		{
+			name:     "All of several tags",
+			rawQuery: "tag=work&tag=urgent&tag=work&tag_match=all",
+			expectedQuery: db.TaskQuery{
+				OwnerID: testUserID,
+				Limit:   db.DefaultTaskPageSize,
+				Sort:    "id",
+				Tags:    []string{"urgent", "work"},
+				AllTags: true,
+			},
+			dbTasks:          []bt.Task{},
+			expectedStatus:   http.StatusOK,
+			expectedResponse: []bt.Task{},
+		},
+		{
+			name:           "Unknown tag match mode",
+			rawQuery:       "tag=work&tag_match=some",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Cursor from another sort order",
+			rawQuery:       "sort=name&cursor=" + nextCursor,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Malformed cursor",
+			rawQuery:       "cursor=not-a-cursor",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Cursor with a malformed timestamp",
+			rawQuery:       "sort=created_at&cursor=" + db.EncodeTaskCursor(&db.TaskCursor{Sort: "created_at", Value: "yesterday", ID: 1}),
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Cursor with a null sentinel on a non-nullable timestamp",
+			rawQuery:       "sort=created_at&cursor=" + db.EncodeTaskCursor(&db.TaskCursor{Sort: "created_at", Value: "infinity", ID: 1}),
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:     "Cursor with a null sentinel on a nullable timestamp",
+			rawQuery: "sort=due_at&cursor=" + db.EncodeTaskCursor(&db.TaskCursor{Sort: "due_at", Value: "infinity", ID: 1}),
+			expectedQuery: db.TaskQuery{
+				OwnerID: testUserID,
+				Limit:   db.DefaultTaskPageSize,
+				Sort:    "due_at",
+				After:   &db.TaskCursor{Sort: "due_at", Value: "infinity", ID: 1},
+			},
+			dbTasks:          []bt.Task{},
+			expectedStatus:   http.StatusOK,
+			expectedResponse: []bt.Task{},
+		},
+		{
+			name:           "Unknown sort field",
+			rawQuery:       "sort=description",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid ID range",
+			rawQuery:       "min_id=10&max_id=2",
+			expectedStatus: http.StatusBadRequest,
+		},
 		{
 			name:             "Failed to get all tasks",
+			expectedQuery:    db.TaskQuery{OwnerID: testUserID, Limit: db.DefaultTaskPageSize, Sort: "id"},
 			dbTasks:          nil,
 			dbGetError:       fmt.Errorf("failed to select tasks from DB"),
 			expectedStatus:   http.StatusInternalServerError,
@@ -272,12 +447,13 @@ func TestGetAllTasksHandler(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockDB.ExpectedCalls = nil
-			mockDB.On("GetAllTasks").Return(tt.dbTasks, tt.dbGetError)
+			mockDB.On("GetAllTasks", &tt.expectedQuery).Return(tt.dbTasks, tt.dbNextCursor, tt.dbGetError)
 
-			req, err := http.NewRequest("GET", "/tasks", nil)
+			req, err := http.NewRequest("GET", "/tasks?"+tt.rawQuery, nil)
 			if err != nil {
 				t.Fatal(err)
 			}
+			req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
 
 			rr := httptest.NewRecorder()
 			h.GetAllTasksHandler(rr, req)
@@ -287,13 +463,19 @@ func TestGetAllTasksHandler(t *testing.T) {
 			}
 
 			if tt.expectedStatus == http.StatusOK {
-				var responseTask []bt.Task
-				if err := json.NewDecoder(rr.Body).Decode(&responseTask); err != nil {
+				var response struct {
+					Tasks      []bt.Task `json:"tasks"`
+					NextCursor string    `json:"next_cursor"`
+				}
+				if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
 					t.Fatal(err)
 				}
 
-				if !reflect.DeepEqual(responseTask, tt.expectedResponse) {
-					t.Errorf("Expected response %v, got %v", tt.expectedResponse, responseTask)
+				if !reflect.DeepEqual(response.Tasks, tt.expectedResponse) {
+					t.Errorf("Expected response %v, got %v", tt.expectedResponse, response.Tasks)
+				}
+				if response.NextCursor != tt.dbNextCursor {
+					t.Errorf("Expected next cursor %q, got %q", tt.dbNextCursor, response.NextCursor)
 				}
 			}
 		})
@@ -308,6 +490,7 @@ func TestUpdateTaskHandler(t *testing.T) {
 	type taskInfo struct {
 		Name        string `json:"name"`
 		Description string `json:"description"`
+		Status      string `json:"status,omitempty"`
 	}
 
 	tests := []struct {
@@ -367,6 +550,27 @@ func TestUpdateTaskHandler(t *testing.T) {
 			expectedStatus:    http.StatusNotFound,
 			expectedResponse:  bt.Task{},
 		},
+		{
+			name:    "Unknown status",
+			inputID: 1,
+			inputInfo: taskInfo{
+				Name:        "Test Task",
+				Description: "Test Description",
+				Status:      "finished",
+			},
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:    "Transition not allowed",
+			inputID: 1,
+			inputInfo: taskInfo{
+				Name:        "Test Task",
+				Description: "Test Description",
+				Status:      bt.StatusBlocked,
+			},
+			dbUpdateError:  db.ErrInvalidTransition,
+			expectedStatus: http.StatusConflict,
+		},
 	}
 
 	for _, tt := range tests {
@@ -374,10 +578,12 @@ func TestUpdateTaskHandler(t *testing.T) {
 			id := strconv.Itoa(tt.inputID)
 
 			mockCache.ExpectedCalls = nil
-			mockCache.On("Delete", tt.inputID).Return(tt.cachedDeleteError)
+			mockCache.On("Delete", testUserID, tt.inputID).Return(tt.cachedDeleteError)
 
 			mockDB.ExpectedCalls = nil
-			mockDB.On("UpdateTask", mock.AnythingOfType("*basic_types.Task")).Return(tt.dbTask, tt.dbUpdateError)
+			mockDB.On("UpdateTask", mock.MatchedBy(func(task *bt.Task) bool {
+				return task.OwnerID == testUserID
+			}), testUserID).Return(tt.dbTask, tt.dbUpdateError)
 
 			body, _ := json.Marshal(tt.inputInfo)
 
@@ -386,6 +592,7 @@ func TestUpdateTaskHandler(t *testing.T) {
 				t.Fatal(err)
 			}
 
+			req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
 			req = mux.SetURLVars(req, map[string]string{
 				"id": id,
 			})
@@ -402,7 +609,7 @@ func TestUpdateTaskHandler(t *testing.T) {
 				if err := json.NewDecoder(rr.Body).Decode(&responseTask); err != nil {
 					t.Fatal(err)
 				}
-				if responseTask != tt.expectedResponse {
+				if !reflect.DeepEqual(responseTask, tt.expectedResponse) {
 					t.Errorf("Expected response %v, got %v", tt.expectedResponse, responseTask)
 				}
 			}
@@ -443,16 +650,17 @@ func TestDeleteTaskHandler(t *testing.T) {
 			id, _ := strconv.Atoi(tt.taskID)
 
 			mockCache.ExpectedCalls = nil
-			mockCache.On("Delete", id).Return(tt.cachedDeleteError)
+			mockCache.On("Delete", testUserID, id).Return(tt.cachedDeleteError)
 
 			mockDB.ExpectedCalls = nil
-			mockDB.On("DeleteTask", id).Return(tt.dbDeleteError)
+			mockDB.On("DeleteTask", testUserID, id, 0, testUserID).Return(tt.dbDeleteError)
 
 			req, err := http.NewRequest("DELETE", "/tasks/"+tt.taskID, nil)
 			if err != nil {
 				t.Fatal(err)
 			}
 
+			req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
 			req = mux.SetURLVars(req, map[string]string{
 				"id": tt.taskID,
 			})
@@ -466,3 +674,230 @@ func TestDeleteTaskHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestTaskHandlersRequireUser(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	mockCache := &mocks.MockTaskCache{}
+	h := &handler.Handler{DB: mockDB, Cache: mockCache}
+
+	tests := []struct {
+		name    string
+		method  string
+		handler http.HandlerFunc
+	}{
+		{name: "Create task", method: "POST", handler: h.CreateTaskHandler},
+		{name: "Get task", method: "GET", handler: h.GetTaskHandler},
+		{name: "Get all tasks", method: "GET", handler: h.GetAllTasksHandler},
+		{name: "Update task", method: "PUT", handler: h.UpdateTaskHandler},
+		{name: "Delete task", method: "DELETE", handler: h.DeleteTaskHandler},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, "/tasks/1", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{
+				"id": "1",
+			})
+
+			rr := httptest.NewRecorder()
+			tt.handler(rr, req)
+
+			if rr.Code != http.StatusUnauthorized {
+				t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, rr.Code)
+			}
+			if len(mockDB.Calls) != 0 {
+				t.Errorf("Expected no DB calls, got %d", len(mockDB.Calls))
+			}
+		})
+	}
+}
+
+func TestRegisterHandler(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	mockCache := &mocks.MockTaskCache{}
+	h := &handler.Handler{DB: mockDB, Cache: mockCache}
+
+	tests := []struct {
+		name                string
+		inputData           db.UserData
+		mockCreateUser      *db.User
+		mockCreateUserError error
+		expectedStatus      int
+	}{
+		{
+			name:                "Succesfully register user",
+			inputData:           db.UserData{Login: "new_user", Password: "passw0rdX"},
+			mockCreateUser:      &db.User{ID: 1, Login: "new_user", Role: "member"},
+			mockCreateUserError: nil,
+			expectedStatus:      http.StatusCreated,
+		},
+		{
+			name:                "User already exists",
+			inputData:           db.UserData{Login: "new_user", Password: "passw0rdX"},
+			mockCreateUser:      nil,
+			mockCreateUserError: db.ErrUserAlreadyExists,
+			expectedStatus:      http.StatusConflict,
+		},
+		{
+			name:           "Invalid login",
+			inputData:      db.UserData{Login: "no spaces allowed", Password: "passw0rdX"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Weak password",
+			inputData:      db.UserData{Login: "new_user", Password: "password"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:                "Long passphrase",
+			inputData:           db.UserData{Login: "new_user", Password: strings.Repeat("correct horse battery staple 1 ", 8)},
+			mockCreateUser:      &db.User{ID: 1, Login: "new_user", Role: "member"},
+			mockCreateUserError: nil,
+			expectedStatus:      http.StatusCreated,
+		},
+		{
+			name:           "Password too long",
+			inputData:      db.UserData{Login: "new_user", Password: strings.Repeat("passw0rd", 33)},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB.ExpectedCalls = nil
+			mockDB.On("CreateUser", mock.AnythingOfType("*db.UserData")).Return(tt.mockCreateUser, tt.mockCreateUserError)
+			mockDB.On("AddRefreshToken", 1, mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).Return(nil)
+
+			body, _ := json.Marshal(tt.inputData)
+
+			req, err := http.NewRequest("POST", "/register", bytes.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			h.RegisterHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+
+			if tt.expectedStatus == http.StatusCreated {
+				var response struct {
+					Token        string `json:"token"`
+					RefreshToken string `json:"refresh_token"`
+				}
+				if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+					t.Fatal(err)
+				}
+				if response.Token == "" || response.RefreshToken == "" {
+					t.Errorf("Expected tokens in response, got %v", response)
+				}
+			}
+		})
+	}
+}
+
+func TestLoginHandler(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	mockLimiter := &mocks.MockLoginLimiter{}
+	h := &handler.Handler{DB: mockDB, Cache: &mocks.MockTaskCache{}, Limiter: mockLimiter}
+
+	tests := []struct {
+		name           string
+		wait           time.Duration
+		dbUser         *db.User
+		dbError        error
+		expectedStatus int
+	}{
+		{
+			name:           "Succesfully log in",
+			dbUser:         &db.User{ID: 1, Login: "user", Role: "member"},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "Two-factor authentication required",
+			dbUser:         &db.User{ID: 1, Login: "user", Role: "member", TOTPEnabled: true},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Unknown user",
+			dbError:        db.ErrUserNotFound,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Incorrect password",
+			dbError:        db.ErrIncorrectPassword,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Too many failed attempts",
+			wait:           90 * time.Second,
+			expectedStatus: http.StatusTooManyRequests,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB.ExpectedCalls = nil
+			mockDB.Calls = nil
+			mockDB.On("CheckUser", mock.AnythingOfType("*db.UserData")).Return(tt.dbUser, tt.dbError)
+			mockDB.On("AddRefreshToken", 1, mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).Return(nil)
+
+			mockLimiter.ExpectedCalls = nil
+			mockLimiter.Calls = nil
+			mockLimiter.On("Check", "user", "192.0.2.1").Return(tt.wait, nil)
+			mockLimiter.On("RegisterFailure", "user", "192.0.2.1").Return(nil)
+			mockLimiter.On("Reset", "user").Return(nil)
+
+			body, _ := json.Marshal(db.UserData{Login: "user", Password: "passw0rdX"})
+
+			req, err := http.NewRequest("POST", "/login", bytes.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.RemoteAddr = "192.0.2.1:54321"
+
+			rr := httptest.NewRecorder()
+			h.LoginHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+
+			switch tt.expectedStatus {
+			case http.StatusCreated:
+				mockLimiter.AssertCalled(t, "Reset", "user")
+			case http.StatusOK:
+				var response struct {
+					MFARequired bool   `json:"mfa_required"`
+					MFAToken    string `json:"mfa_token"`
+					Token       string `json:"token"`
+				}
+				if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+					t.Fatal(err)
+				}
+				if !response.MFARequired || response.MFAToken == "" || response.Token != "" {
+					t.Errorf("Expected only MFA token in response, got %+v", response)
+				}
+				if _, err := auth.ValidateToken(response.MFAToken); err == nil {
+					t.Errorf("Expected MFA token to be rejected as access token")
+				}
+			case http.StatusUnauthorized:
+				mockLimiter.AssertCalled(t, "RegisterFailure", "user", "192.0.2.1")
+				if body := rr.Body.String(); body != "Invalid login or password\n" {
+					t.Errorf("Expected uniform error message, got %q", body)
+				}
+			case http.StatusTooManyRequests:
+				mockDB.AssertNotCalled(t, "CheckUser", mock.Anything)
+				if retry := rr.Header().Get("Retry-After"); retry != "90" {
+					t.Errorf("Expected Retry-After 90, got %q", retry)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	bt "restapi/basic_types"
+	db "restapi/db"
+	"restapi/handler"
+	"restapi/tests/mocks"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestGetTaskHistoryHandler(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	h := &handler.Handler{DB: mockDB, Cache: &mocks.MockTaskCache{}}
+
+	actor := testUserID
+	revisions := []db.TaskRevision{
+		{Revision: 1, Operation: db.OpCreate, ActorID: &actor, Task: bt.Task{ID: 1, Name: "Draft", Version: 1}},
+		{Revision: 2, Operation: db.OpUpdate, ActorID: &actor, Task: bt.Task{ID: 1, Name: "Final", Version: 2}},
+	}
+	mockDB.On("GetTaskRevisions", testUserID, 1).Return(revisions, nil)
+	mockDB.On("GetTaskRevisions", testUserID, 2).Return([]db.TaskRevision(nil), db.ErrTaskNotFound)
+
+	tests := []struct {
+		name           string
+		id             string
+		expectedStatus int
+		expectedCount  int
+	}{
+		{name: "Succesfully get history", id: "1", expectedStatus: http.StatusOK, expectedCount: 2},
+		{name: "Task not found", id: "2", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/tasks/"+tt.id+"/history", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+			req = mux.SetURLVars(req, map[string]string{"id": tt.id})
+
+			rr := httptest.NewRecorder()
+			h.GetTaskHistoryHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var got []db.TaskRevision
+			if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != tt.expectedCount {
+				t.Fatalf("Expected %d revisions, got %d", tt.expectedCount, len(got))
+			}
+			if got[1].Task.Name != "Final" || got[1].ActorID == nil || *got[1].ActorID != testUserID {
+				t.Errorf("Unexpected revision: %+v", got[1])
+			}
+		})
+	}
+}
+
+func TestGetTaskAsOf(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	mockCache := &mocks.MockTaskCache{}
+	h := &handler.Handler{DB: mockDB, Cache: mockCache}
+
+	at := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	mockDB.On("GetTaskAsOf", testUserID, 1, at).Return(&bt.Task{ID: 1, Name: "Draft", Version: 1}, nil)
+	mockDB.On("GetTaskAsOf", testUserID, 2, at).Return((*bt.Task)(nil), db.ErrTaskNotFound)
+
+	tests := []struct {
+		name           string
+		id             string
+		asOf           string
+		expectedStatus int
+	}{
+		{name: "Succesfully get past version", id: "1", asOf: "2024-03-10T12:00:00Z", expectedStatus: http.StatusOK},
+		{name: "Task did not exist", id: "2", asOf: "2024-03-10T12:00:00Z", expectedStatus: http.StatusNotFound},
+		{name: "Invalid timestamp", id: "1", asOf: "yesterday", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/tasks/"+tt.id+"?as_of="+tt.asOf, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+			req = mux.SetURLVars(req, map[string]string{"id": tt.id})
+
+			rr := httptest.NewRecorder()
+			h.GetTaskHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if tt.expectedStatus == http.StatusOK && rr.Header().Get("ETag") != `"1"` {
+				t.Errorf(`Expected ETag "1", got %s`, rr.Header().Get("ETag"))
+			}
+		})
+	}
+
+	mockCache.AssertNotCalled(t, "Get")
+	mockCache.AssertNotCalled(t, "Set")
+}
+
+func TestRevertTaskHandler(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	mockCache := &mocks.MockTaskCache{}
+	h := &handler.Handler{DB: mockDB, Cache: mockCache}
+
+	mockDB.On("RevertTask", testUserID, 1, 1, testUserID).Return(&bt.Task{ID: 1, Name: "Draft", Version: 3}, nil)
+	mockDB.On("RevertTask", testUserID, 1, 9, testUserID).Return((*bt.Task)(nil), db.ErrRevisionNotFound)
+	mockDB.On("RevertTask", testUserID, 1, 2, testUserID).Return((*bt.Task)(nil), db.ErrInvalidTransition)
+	mockCache.On("Delete", testUserID, 1).Return(nil)
+
+	tests := []struct {
+		name           string
+		rev            string
+		expectedStatus int
+	}{
+		{name: "Succesfully revert task", rev: "1", expectedStatus: http.StatusOK},
+		{name: "Unknown revision", rev: "9", expectedStatus: http.StatusNotFound},
+		{name: "Illegal status transition", rev: "2", expectedStatus: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", "/tasks/1/revert/"+tt.rev, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+			req = mux.SetURLVars(req, map[string]string{"id": "1", "rev": tt.rev})
+
+			rr := httptest.NewRecorder()
+			h.RevertTaskHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if tt.expectedStatus == http.StatusOK && rr.Header().Get("ETag") != `"3"` {
+				t.Errorf(`Expected ETag "3", got %s`, rr.Header().Get("ETag"))
+			}
+		})
+	}
+
+	mockCache.AssertNumberOfCalls(t, "Delete", 1)
+}
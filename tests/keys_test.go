@@ -0,0 +1,224 @@
+package tests
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"restapi/auth"
+	"restapi/handler"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func useKeySet(t *testing.T, ks *auth.KeySet) {
+	auth.SetKeySet(ks)
+	t.Cleanup(func() { auth.SetKeySet(nil) })
+}
+
+func TestKeySetSigningMethods(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		name string
+		key  *auth.SigningKey
+		alg  string
+	}{
+		{name: "HS256", key: auth.NewHS256Key("hs", []byte("secret")), alg: "HS256"},
+		{name: "RS256", key: auth.NewRS256Key("rsa", rsaKey), alg: "RS256"},
+		{name: "EdDSA", key: auth.NewEdDSAKey("ed", edKey), alg: "EdDSA"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks := auth.NewKeySet(time.Hour)
+			ks.Add(tt.key)
+			useKeySet(t, ks)
+
+			token, err := auth.GenerateToken(testUserID, auth.RoleMember)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &auth.Claims{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed.Header["kid"] != tt.key.ID || parsed.Method.Alg() != tt.alg {
+				t.Errorf("Expected kid %q and alg %s, got %v", tt.key.ID, tt.alg, parsed.Header)
+			}
+
+			claims, err := auth.ValidateToken(token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.UserID != testUserID {
+				t.Errorf("Expected user %d, got %d", testUserID, claims.UserID)
+			}
+		})
+	}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	_, oldKey, _ := ed25519.GenerateKey(rand.Reader)
+	_, newKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	t.Run("Retired key verifies during grace period", func(t *testing.T) {
+		ks := auth.NewKeySet(time.Hour)
+		ks.Add(auth.NewEdDSAKey("old", oldKey))
+		useKeySet(t, ks)
+
+		oldToken, _ := auth.GenerateToken(testUserID, auth.RoleMember)
+		ks.Rotate(auth.NewEdDSAKey("new", newKey))
+		newToken, _ := auth.GenerateToken(testUserID, auth.RoleMember)
+
+		if _, err := auth.ValidateToken(oldToken); err != nil {
+			t.Errorf("Expected token signed by retired key to be valid, got %v", err)
+		}
+		if _, err := auth.ValidateToken(newToken); err != nil {
+			t.Errorf("Expected token signed by new key to be valid, got %v", err)
+		}
+	})
+
+	t.Run("Retired key is rejected after grace period", func(t *testing.T) {
+		ks := auth.NewKeySet(time.Hour)
+		ks.Add(auth.NewEdDSAKey("old", oldKey))
+		ks.Add(auth.NewEdDSAKey("new", newKey))
+		useKeySet(t, ks)
+
+		oldToken, _ := auth.GenerateToken(testUserID, auth.RoleMember)
+		ks.Retire("old", time.Now().Add(-2*time.Hour))
+
+		if _, err := auth.ValidateToken(oldToken); !errors.Is(err, auth.ErrInvalidToken) {
+			t.Errorf("Expected ErrInvalidToken, got %v", err)
+		}
+	})
+
+	t.Run("Retiring a key while tokens are verified", func(t *testing.T) {
+		ks := auth.NewKeySet(time.Hour)
+		ks.Add(auth.NewEdDSAKey("old", oldKey))
+		ks.Add(auth.NewEdDSAKey("new", newKey))
+		useKeySet(t, ks)
+
+		token, _ := auth.GenerateToken(testUserID, auth.RoleMember)
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				ks.Retire("old", time.Now())
+			}
+		}()
+		for i := 0; i < 100; i++ {
+			if _, err := auth.ValidateToken(token); err != nil {
+				t.Errorf("Expected token to stay valid during the grace period, got %v", err)
+				break
+			}
+		}
+		wg.Wait()
+	})
+
+	t.Run("Signing method must match key", func(t *testing.T) {
+		ks := auth.NewKeySet(time.Hour)
+		ks.Add(auth.NewEdDSAKey("ed", newKey))
+		useKeySet(t, ks)
+
+		forged := jwt.NewWithClaims(jwt.SigningMethodHS256, auth.Claims{UserID: testUserID})
+		forged.Header["kid"] = "ed"
+		token, _ := forged.SignedString([]byte(newKey.Public().(ed25519.PublicKey)))
+
+		if _, err := auth.ValidateToken(token); !errors.Is(err, auth.ErrInvalidToken) {
+			t.Errorf("Expected ErrInvalidToken, got %v", err)
+		}
+	})
+}
+
+func TestJWKSHandler(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	ks := auth.NewKeySet(time.Hour)
+	ks.Add(auth.NewHS256Key("hs", []byte("secret")))
+	ks.Add(auth.NewRS256Key("rsa", rsaKey))
+	ks.Add(auth.NewEdDSAKey("ed", edKey))
+	useKeySet(t, ks)
+
+	h := &handler.Handler{}
+	req, err := http.NewRequest("GET", "/.well-known/jwks.json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	h.JWKSHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var jwks auth.JWKS
+	if err := json.NewDecoder(rr.Body).Decode(&jwks); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("Expected 2 public keys, got %v", jwks.Keys)
+	}
+	if jwks.Keys[0].KeyID != "ed" || jwks.Keys[0].KeyType != "OKP" || jwks.Keys[0].X == "" {
+		t.Errorf("Unexpected Ed25519 key %v", jwks.Keys[0])
+	}
+	if jwks.Keys[1].KeyID != "rsa" || jwks.Keys[1].KeyType != "RSA" || jwks.Keys[1].E != "AQAB" {
+		t.Errorf("Unexpected RSA key %v", jwks.Keys[1])
+	}
+}
+
+func TestLoadKeySetFromEnv(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	rsaPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+	os.WriteFile(filepath.Join(dir, "2026-01.pem"), rsaPEM, 0600)
+
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	edDER, _ := x509.MarshalPKCS8PrivateKey(edKey)
+	edPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: edDER})
+	os.WriteFile(filepath.Join(dir, "2026-10.pem"), edPEM, 0600)
+
+	t.Setenv("SECRET_KEY", "")
+	t.Setenv("JWT_KEYS_DIR", dir)
+	t.Setenv("JWT_SIGNING_KID", "2026-10")
+	t.Setenv("JWT_RETIRED_KEYS", "2026-01="+time.Now().Format(time.RFC3339))
+
+	ks, err := auth.LoadKeySetFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	useKeySet(t, ks)
+
+	token, _ := auth.GenerateToken(testUserID, auth.RoleMember)
+	parsed, _, _ := jwt.NewParser().ParseUnverified(token, &auth.Claims{})
+	if parsed.Header["kid"] != "2026-10" {
+		t.Errorf("Expected token signed with kid 2026-10, got %v", parsed.Header["kid"])
+	}
+
+	if keys := ks.JWKS().Keys; len(keys) != 2 {
+		t.Errorf("Expected retired key to stay published during grace period, got %v", keys)
+	}
+
+	t.Setenv("JWT_SIGNING_KID", "")
+	if _, err := auth.LoadKeySetFromEnv(); err == nil {
+		t.Errorf("Expected error when several keys are configured without JWT_SIGNING_KID")
+	}
+}
@@ -0,0 +1,228 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"restapi/auth"
+	"restapi/cache"
+	db "restapi/db"
+	"restapi/handler"
+	"restapi/tests/mocks"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+const testTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B test vectors for SHA-1, truncated to 6 digits.
+	tests := []struct {
+		unix     int64
+		expected string
+	}{
+		{unix: 59, expected: "287082"},
+		{unix: 1111111109, expected: "081804"},
+		{unix: 1234567890, expected: "005924"},
+		{unix: 2000000000, expected: "279037"},
+	}
+
+	for _, tt := range tests {
+		code, err := auth.TOTPCode(testTOTPSecret, auth.TOTPStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != tt.expected {
+			t.Errorf("Expected code %s at %d, got %s", tt.expected, tt.unix, code)
+		}
+	}
+
+	now := time.Now()
+	previous, _ := auth.TOTPCode(testTOTPSecret, auth.TOTPStep(now)-1)
+	if _, ok := auth.ValidateTOTP(testTOTPSecret, previous, now); !ok {
+		t.Errorf("Expected code from previous period to be accepted")
+	}
+	stale, _ := auth.TOTPCode(testTOTPSecret, auth.TOTPStep(now)-3)
+	if _, ok := auth.ValidateTOTP(testTOTPSecret, stale, now); ok {
+		t.Errorf("Expected stale code to be rejected")
+	}
+}
+
+func TestConfirmTOTPHandler(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	h := &handler.Handler{DB: mockDB, Cache: &mocks.MockTaskCache{}}
+
+	current, _ := auth.TOTPCode(testTOTPSecret, auth.TOTPStep(time.Now()))
+
+	tests := []struct {
+		name           string
+		state          *db.TOTPState
+		code           string
+		expectedStatus int
+	}{
+		{
+			name:           "Succesfully confirm enrollment",
+			state:          &db.TOTPState{Secret: testTOTPSecret},
+			code:           current,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Invalid code",
+			state:          &db.TOTPState{Secret: testTOTPSecret},
+			code:           "000000",
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "Already enabled",
+			state:          &db.TOTPState{Secret: testTOTPSecret, Enabled: true},
+			code:           current,
+			expectedStatus: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB.ExpectedCalls = nil
+			mockDB.On("GetTOTP", testUserID).Return(tt.state, nil)
+			mockDB.On("UseTOTPStep", testUserID, mock.AnythingOfType("int64")).Return(nil)
+			mockDB.On("EnableTOTP", testUserID, mock.AnythingOfType("[]string")).Return(nil)
+
+			body, _ := json.Marshal(map[string]string{"code": tt.code})
+
+			req, err := http.NewRequest("POST", "/me/mfa/totp/confirm", bytes.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+
+			rr := httptest.NewRecorder()
+			h.ConfirmTOTPHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+
+			if tt.expectedStatus == http.StatusOK {
+				var response struct {
+					RecoveryCodes []string `json:"recovery_codes"`
+				}
+				if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+					t.Fatal(err)
+				}
+				if len(response.RecoveryCodes) != 10 {
+					t.Errorf("Expected 10 recovery codes, got %v", response.RecoveryCodes)
+				}
+			}
+		})
+	}
+}
+
+func TestLoginMFAHandler(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	mockLimiter := &mocks.MockLoginLimiter{}
+	h := &handler.Handler{
+		DB:      mockDB,
+		Cache:   &mocks.MockTaskCache{},
+		Revoked: cache.NewMemoryRevocationList(),
+		Limiter: mockLimiter,
+	}
+
+	const userID = 1
+	current, _ := auth.TOTPCode(testTOTPSecret, auth.TOTPStep(time.Now()))
+	accessToken, _ := auth.GenerateToken(userID, auth.RoleMember)
+	usedMFAToken, _ := auth.GenerateMFAToken(userID)
+
+	tests := []struct {
+		name           string
+		mfaToken       string
+		code           string
+		recoveryCode   string
+		stepError      error
+		recoveryError  error
+		expectedStatus int
+	}{
+		{
+			name:           "Succesfully log in with TOTP code",
+			mfaToken:       usedMFAToken,
+			code:           current,
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "MFA token is single-use",
+			mfaToken:       usedMFAToken,
+			code:           current,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Replayed TOTP code",
+			code:           current,
+			stepError:      db.ErrTOTPCodeReused,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Wrong TOTP code",
+			code:           "000000",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Succesfully log in with recovery code",
+			recoveryCode:   "abcde-fghij",
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "Used recovery code",
+			recoveryCode:   "abcde-fghij",
+			recoveryError:  db.ErrInvalidRecoveryCode,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Access token instead of MFA token",
+			mfaToken:       accessToken,
+			code:           current,
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mfaToken := tt.mfaToken
+			if mfaToken == "" {
+				mfaToken, _ = auth.GenerateMFAToken(userID)
+			}
+
+			mockDB.ExpectedCalls = nil
+			mockDB.On("GetTOTP", userID).Return(&db.TOTPState{Secret: testTOTPSecret, Enabled: true}, nil)
+			mockDB.On("UseTOTPStep", userID, mock.AnythingOfType("int64")).Return(tt.stepError)
+			mockDB.On("UseRecoveryCode", userID, auth.HashRecoveryCode("ABCDEFGHIJ")).Return(tt.recoveryError)
+			mockDB.On("GetUser", userID).Return(&db.User{ID: userID, Role: auth.RoleMember, TOTPEnabled: true}, nil)
+			mockDB.On("AddRefreshToken", userID, mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).Return(nil)
+
+			mockLimiter.ExpectedCalls = nil
+			mockLimiter.On("Check", "mfa:1", mock.AnythingOfType("string")).Return(time.Duration(0), nil)
+			mockLimiter.On("RegisterFailure", "mfa:1", mock.AnythingOfType("string")).Return(nil)
+			mockLimiter.On("Reset", "mfa:1").Return(nil)
+
+			body, _ := json.Marshal(map[string]string{
+				"mfa_token":     mfaToken,
+				"code":          tt.code,
+				"recovery_code": tt.recoveryCode,
+			})
+
+			req, err := http.NewRequest("POST", "/login/mfa", bytes.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			h.LoginMFAHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, rr.Code, strings.TrimSpace(rr.Body.String()))
+			}
+		})
+	}
+}
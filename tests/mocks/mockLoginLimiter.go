@@ -0,0 +1,26 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockLoginLimiter struct {
+	mock.Mock
+}
+
+func (m *MockLoginLimiter) Check(login, ip string) (time.Duration, error) {
+	args := m.Called(login, ip)
+	return args.Get(0).(time.Duration), args.Error(1)
+}
+
+func (m *MockLoginLimiter) RegisterFailure(login, ip string) error {
+	args := m.Called(login, ip)
+	return args.Error(0)
+}
+
+func (m *MockLoginLimiter) Reset(login string) error {
+	args := m.Called(login)
+	return args.Error(0)
+}
@@ -0,0 +1,16 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockNotifier struct {
+	mock.Mock
+}
+
+func (m *MockNotifier) SendPasswordReset(login, token string, expiresAt time.Time) error {
+	args := m.Called(login, token, expiresAt)
+	return args.Error(0)
+}
@@ -10,8 +10,8 @@ type MockTaskCache struct {
 	mock.Mock
 }
 
-func (m *MockTaskCache) Get(taskID int) (*bt.Task, error) {
-	args := m.Called(taskID)
+func (m *MockTaskCache) Get(ownerID, taskID int) (*bt.Task, error) {
+	args := m.Called(ownerID, taskID)
 	return args.Get(0).(*bt.Task), args.Error(1)
 }
 
@@ -20,7 +20,7 @@ func (m *MockTaskCache) Set(task *bt.Task) error {
 	return args.Error(0)
 }
 
-func (m *MockTaskCache) Delete(taskID int) error {
-	args := m.Called(taskID)
+func (m *MockTaskCache) Delete(ownerID, taskID int) error {
+	args := m.Called(ownerID, taskID)
 	return args.Error(0)
 }
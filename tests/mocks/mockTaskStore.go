@@ -3,6 +3,7 @@ package mocks
 import (
 	bt "restapi/basic_types"
 	"restapi/db"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -11,32 +12,277 @@ type MockTaskStore struct {
 	mock.Mock
 }
 
-func (m *MockTaskStore) AddTask(task *bt.Task) error {
-	args := m.Called(task)
+func (m *MockTaskStore) CreateTask(task *bt.Task, actorID int) error {
+	args := m.Called(task, actorID)
 	return args.Error(0)
 }
 
-func (m *MockTaskStore) GetTask(id int) (*bt.Task, error) {
-	args := m.Called(id)
+func (m *MockTaskStore) AddTask(task *bt.Task, actorID int) error {
+	args := m.Called(task, actorID)
+	return args.Error(0)
+}
+
+func (m *MockTaskStore) GetTask(ownerID, taskID int) (*bt.Task, error) {
+	args := m.Called(ownerID, taskID)
 	return args.Get(0).(*bt.Task), args.Error(1)
 }
 
-func (m *MockTaskStore) GetAllTasks() ([]bt.Task, error) {
-	args := m.Called()
-	return args.Get(0).([]bt.Task), args.Error(1)
+func (m *MockTaskStore) GetAllTasks(query *db.TaskQuery) ([]bt.Task, string, error) {
+	args := m.Called(query)
+	return args.Get(0).([]bt.Task), args.String(1), args.Error(2)
 }
 
-func (m *MockTaskStore) DeleteTask(id int) error {
-	args := m.Called(id)
+// PatchTask applies patch to the task given to Return, the way
+// PostgresStore does inside its transaction.
+func (m *MockTaskStore) PatchTask(ownerID, taskID, actorID int, patch func(task *bt.Task) error) (*bt.Task, error) {
+	args := m.Called(ownerID, taskID, actorID)
+	if err := args.Error(1); err != nil {
+		return nil, err
+	}
+	return db.ApplyTaskPatch(args.Get(0).(*bt.Task), patch, time.Now())
+}
+
+func (m *MockTaskStore) DeleteTask(ownerID, taskID, version, actorID int) error {
+	args := m.Called(ownerID, taskID, version, actorID)
 	return args.Error(0)
 }
 
-func (m *MockTaskStore) UpdateTask(task *bt.Task) (*bt.Task, error) {
-	args := m.Called(task)
+// SearchTasks runs db.NaiveSearchTasks over the tasks given to Return, so
+// tests set up a corpus instead of the expected results.
+func (m *MockTaskStore) SearchTasks(ownerID int, query string, limit int) ([]db.TaskSearchResult, error) {
+	args := m.Called(ownerID, query, limit)
+	if err := args.Error(1); err != nil {
+		return nil, err
+	}
+	return db.NaiveSearchTasks(args.Get(0).([]bt.Task), query, limit), nil
+}
+
+func (m *MockTaskStore) UpdateTask(task *bt.Task, actorID int) (*bt.Task, error) {
+	args := m.Called(task, actorID)
+	return args.Get(0).(*bt.Task), args.Error(1)
+}
+
+func (m *MockTaskStore) RestoreTask(ownerID, taskID, actorID int) (*bt.Task, error) {
+	args := m.Called(ownerID, taskID, actorID)
+	return args.Get(0).(*bt.Task), args.Error(1)
+}
+
+func (m *MockTaskStore) PurgeDeletedTasks(before time.Time) (int64, []string, error) {
+	args := m.Called(before)
+	return args.Get(0).(int64), args.Get(1).([]string), args.Error(2)
+}
+
+func (m *MockTaskStore) GetTaskRevisions(ownerID, taskID int) ([]db.TaskRevision, error) {
+	args := m.Called(ownerID, taskID)
+	return args.Get(0).([]db.TaskRevision), args.Error(1)
+}
+
+func (m *MockTaskStore) GetTaskAsOf(ownerID, taskID int, at time.Time) (*bt.Task, error) {
+	args := m.Called(ownerID, taskID, at)
+	return args.Get(0).(*bt.Task), args.Error(1)
+}
+
+func (m *MockTaskStore) RevertTask(ownerID, taskID, revision, actorID int) (*bt.Task, error) {
+	args := m.Called(ownerID, taskID, revision, actorID)
 	return args.Get(0).(*bt.Task), args.Error(1)
 }
 
-func (m *MockTaskStore) CheckUser(data *db.UserData) (int, error) {
+func (m *MockTaskStore) SetTaskParent(ownerID, taskID int, parentID *int, actorID int) (*bt.Task, error) {
+	args := m.Called(ownerID, taskID, parentID, actorID)
+	return args.Get(0).(*bt.Task), args.Error(1)
+}
+
+func (m *MockTaskStore) AddTaskBlocker(ownerID, taskID, blockerID int) error {
+	args := m.Called(ownerID, taskID, blockerID)
+	return args.Error(0)
+}
+
+func (m *MockTaskStore) RemoveTaskBlocker(ownerID, taskID, blockerID int) error {
+	args := m.Called(ownerID, taskID, blockerID)
+	return args.Error(0)
+}
+
+func (m *MockTaskStore) GetTaskTree(ownerID, taskID int) (*db.TaskNode, error) {
+	args := m.Called(ownerID, taskID)
+	return args.Get(0).(*db.TaskNode), args.Error(1)
+}
+
+func (m *MockTaskStore) GetTags(ownerID int) ([]db.TagCount, error) {
+	args := m.Called(ownerID)
+	return args.Get(0).([]db.TagCount), args.Error(1)
+}
+
+func (m *MockTaskStore) AddComment(comment *bt.Comment) error {
+	args := m.Called(comment)
+	return args.Error(0)
+}
+
+func (m *MockTaskStore) GetComments(ownerID, taskID, afterID, limit int) ([]bt.Comment, int, error) {
+	args := m.Called(ownerID, taskID, afterID, limit)
+	return args.Get(0).([]bt.Comment), args.Int(1), args.Error(2)
+}
+
+func (m *MockTaskStore) UpdateComment(ownerID, taskID, commentID, authorID int, body string) (*bt.Comment, error) {
+	args := m.Called(ownerID, taskID, commentID, authorID, body)
+	return args.Get(0).(*bt.Comment), args.Error(1)
+}
+
+func (m *MockTaskStore) DeleteComment(ownerID, taskID, commentID int) error {
+	args := m.Called(ownerID, taskID, commentID)
+	return args.Error(0)
+}
+
+func (m *MockTaskStore) AddAttachment(attachment *bt.Attachment) error {
+	args := m.Called(attachment)
+	return args.Error(0)
+}
+
+func (m *MockTaskStore) GetAttachments(ownerID, taskID int) ([]bt.Attachment, error) {
+	args := m.Called(ownerID, taskID)
+	return args.Get(0).([]bt.Attachment), args.Error(1)
+}
+
+func (m *MockTaskStore) GetAttachment(ownerID, taskID, attachmentID int) (*bt.Attachment, error) {
+	args := m.Called(ownerID, taskID, attachmentID)
+	return args.Get(0).(*bt.Attachment), args.Error(1)
+}
+
+func (m *MockTaskStore) DeleteAttachment(ownerID, taskID, attachmentID int) (string, error) {
+	args := m.Called(ownerID, taskID, attachmentID)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockTaskStore) CreateProject(project *bt.Project) error {
+	args := m.Called(project)
+	return args.Error(0)
+}
+
+func (m *MockTaskStore) GetProject(ownerID, projectID int) (*bt.Project, error) {
+	args := m.Called(ownerID, projectID)
+	return args.Get(0).(*bt.Project), args.Error(1)
+}
+
+func (m *MockTaskStore) GetAllProjects(ownerID int) ([]bt.Project, error) {
+	args := m.Called(ownerID)
+	return args.Get(0).([]bt.Project), args.Error(1)
+}
+
+func (m *MockTaskStore) UpdateProject(project *bt.Project) (*bt.Project, error) {
+	args := m.Called(project)
+	return args.Get(0).(*bt.Project), args.Error(1)
+}
+
+func (m *MockTaskStore) DeleteProject(ownerID, projectID int, cascade bool, actorID int) ([]int, error) {
+	args := m.Called(ownerID, projectID, cascade, actorID)
+	return args.Get(0).([]int), args.Error(1)
+}
+
+func (m *MockTaskStore) CreateUser(data *db.UserData) (*db.User, error) {
 	args := m.Called(data)
+	return args.Get(0).(*db.User), args.Error(1)
+}
+
+func (m *MockTaskStore) CheckUser(data *db.UserData) (*db.User, error) {
+	args := m.Called(data)
+	return args.Get(0).(*db.User), args.Error(1)
+}
+
+func (m *MockTaskStore) GetUser(userID int) (*db.User, error) {
+	args := m.Called(userID)
+	return args.Get(0).(*db.User), args.Error(1)
+}
+
+func (m *MockTaskStore) GetAllUsers() ([]db.User, error) {
+	args := m.Called()
+	return args.Get(0).([]db.User), args.Error(1)
+}
+
+func (m *MockTaskStore) SetUserRole(userID int, role string) (*db.User, error) {
+	args := m.Called(userID, role)
+	return args.Get(0).(*db.User), args.Error(1)
+}
+
+func (m *MockTaskStore) AddRefreshToken(userID int, tokenHash string, expiresAt time.Time) error {
+	args := m.Called(userID, tokenHash, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockTaskStore) RotateRefreshToken(oldHash, newHash string, expiresAt time.Time) (int, error) {
+	args := m.Called(oldHash, newHash, expiresAt)
+	return args.Get(0).(int), args.Error(1)
+}
+
+func (m *MockTaskStore) RevokeRefreshToken(tokenHash string) error {
+	args := m.Called(tokenHash)
+	return args.Error(0)
+}
+
+func (m *MockTaskStore) RevokeUserRefreshTokens(userID int) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func (m *MockTaskStore) AddAPIKey(key *db.APIKey, keyHash string) error {
+	args := m.Called(key, keyHash)
+	return args.Error(0)
+}
+
+func (m *MockTaskStore) GetAllAPIKeys(userID int) ([]db.APIKey, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]db.APIKey), args.Error(1)
+}
+
+func (m *MockTaskStore) RevokeAPIKey(userID, keyID int) error {
+	args := m.Called(userID, keyID)
+	return args.Error(0)
+}
+
+func (m *MockTaskStore) AuthenticateAPIKey(keyHash string) (*db.APIKey, string, error) {
+	args := m.Called(keyHash)
+	return args.Get(0).(*db.APIKey), args.String(1), args.Error(2)
+}
+
+func (m *MockTaskStore) SetTOTPSecret(userID int, secret string) error {
+	args := m.Called(userID, secret)
+	return args.Error(0)
+}
+
+func (m *MockTaskStore) GetTOTP(userID int) (*db.TOTPState, error) {
+	args := m.Called(userID)
+	return args.Get(0).(*db.TOTPState), args.Error(1)
+}
+
+func (m *MockTaskStore) EnableTOTP(userID int, recoveryCodeHashes []string) error {
+	args := m.Called(userID, recoveryCodeHashes)
+	return args.Error(0)
+}
+
+func (m *MockTaskStore) DisableTOTP(userID int) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func (m *MockTaskStore) UseTOTPStep(userID int, step int64) error {
+	args := m.Called(userID, step)
+	return args.Error(0)
+}
+
+func (m *MockTaskStore) UseRecoveryCode(userID int, codeHash string) error {
+	args := m.Called(userID, codeHash)
+	return args.Error(0)
+}
+
+func (m *MockTaskStore) ChangePassword(userID int, currentPassword, newPassword string) error {
+	args := m.Called(userID, currentPassword, newPassword)
+	return args.Error(0)
+}
+
+func (m *MockTaskStore) AddPasswordResetToken(login, tokenHash string, expiresAt time.Time) (*db.User, error) {
+	args := m.Called(login, tokenHash, expiresAt)
+	return args.Get(0).(*db.User), args.Error(1)
+}
+
+func (m *MockTaskStore) ResetPassword(tokenHash, newPassword string) (int, error) {
+	args := m.Called(tokenHash, newPassword)
 	return args.Get(0).(int), args.Error(1)
 }
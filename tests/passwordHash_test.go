@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	db "restapi/db"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+)
+
+func legacyHash(password string) string {
+	h := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(h[:])
+}
+
+// argon2Hash encodes an argon2id hash with the given parameters, which may
+// differ from the ones HashPassword uses.
+func argon2Hash(password string, memory, time uint32, threads uint8) string {
+	salt := []byte("0123456789abcdef")
+	key := argon2.IDKey([]byte(password), salt, time, memory, threads, 32)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, memory, time, threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+func TestCheckPassword(t *testing.T) {
+	current, err := db.HashPassword("password1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(current, "$argon2id$v=19$") {
+		t.Fatalf("Unexpected hash format %q", current)
+	}
+
+	tests := []struct {
+		name            string
+		password        string
+		stored          string
+		expectedOK      bool
+		expectedUpgrade bool
+	}{
+		{name: "Argon2id round trip", password: "password1", stored: current, expectedOK: true},
+		{name: "Wrong password", password: "password2", stored: current},
+		{name: "Legacy SHA-256 hash is upgraded", password: "password1", stored: legacyHash("password1"),
+			expectedOK: true, expectedUpgrade: true},
+		{name: "Wrong password against legacy hash", password: "password2", stored: legacyHash("password1")},
+		{name: "Outdated argon2 parameters are upgraded", password: "password1", stored: argon2Hash("password1", 32*1024, 2, 1),
+			expectedOK: true, expectedUpgrade: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, upgraded, err := db.CheckPassword(tt.password, tt.stored)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.expectedOK {
+				t.Errorf("Expected ok %v, got %v", tt.expectedOK, ok)
+			}
+			if (upgraded != "") != tt.expectedUpgrade {
+				t.Fatalf("Expected upgrade %v, got %q", tt.expectedUpgrade, upgraded)
+			}
+			if upgraded == "" {
+				return
+			}
+
+			// The upgraded hash is what the login stores, and it must not
+			// be upgraded again.
+			ok, again, err := db.CheckPassword(tt.password, upgraded)
+			if err != nil || !ok || again != "" {
+				t.Errorf("Expected upgraded hash to verify without a further upgrade, got %v, %q, %v", ok, again, err)
+			}
+		})
+	}
+}
+
+func TestCheckPasswordMalformedHash(t *testing.T) {
+	valid := argon2Hash("password1", 64*1024, 3, 2)
+	parts := strings.Split(valid, "$")
+
+	tests := []struct {
+		name   string
+		stored string
+	}{
+		{name: "Empty", stored: ""},
+		{name: "Truncated legacy hash", stored: legacyHash("password1")[:40]},
+		{name: "Legacy hash that is not hex", stored: strings.Repeat("z", 64)},
+		{name: "Missing key", stored: strings.Join(parts[:5], "$")},
+		{name: "Empty key", stored: strings.Join(parts[:5], "$") + "$"},
+		{name: "Truncated key", stored: strings.Join(parts[:5], "$") + "$" + parts[5][:3]},
+		{name: "Empty salt", stored: strings.Join([]string{"", parts[1], parts[2], parts[3], "", parts[5]}, "$")},
+		{name: "Unknown algorithm", stored: strings.Replace(valid, "argon2id", "argon2i", 1)},
+		{name: "Unknown version", stored: strings.Replace(valid, "v=19", "v=16", 1)},
+		{name: "Malformed parameters", stored: strings.Replace(valid, "m=65536", "m=lots", 1)},
+		{name: "Zero time", stored: strings.Replace(valid, "t=3", "t=0", 1)},
+		{name: "Zero threads", stored: strings.Replace(valid, "p=2", "p=0", 1)},
+		{name: "Invalid salt encoding", stored: strings.Replace(valid, parts[4], "!!!", 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, upgraded, err := db.CheckPassword("password1", tt.stored)
+			if err == nil {
+				t.Errorf("Expected an error, got ok %v", ok)
+			}
+			if ok || upgraded != "" {
+				t.Errorf("Expected malformed hash to be rejected, got ok %v, upgrade %q", ok, upgraded)
+			}
+		})
+	}
+}
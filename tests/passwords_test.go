@@ -0,0 +1,266 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"restapi/auth"
+	"restapi/cache"
+	db "restapi/db"
+	"restapi/handler"
+	"restapi/notify"
+	"restapi/tests/mocks"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func TestChangePasswordHandler(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	revoked := cache.NewMemoryRevocationList()
+	h := &handler.Handler{DB: mockDB, Cache: &mocks.MockTaskCache{}, Revoked: revoked}
+
+	tests := []struct {
+		name           string
+		newPassword    string
+		changeError    error
+		expectedStatus int
+	}{
+		{
+			name:           "Succesfully change password",
+			newPassword:    "newpassword1",
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "Wrong current password",
+			newPassword:    "newpassword1",
+			changeError:    db.ErrIncorrectPassword,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "Weak new password",
+			newPassword:    "short",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB.ExpectedCalls = nil
+			mockDB.Calls = nil
+			mockDB.On("ChangePassword", testUserID, "oldpassword1", tt.newPassword).Return(tt.changeError)
+			mockDB.On("RevokeUserRefreshTokens", testUserID).Return(nil)
+			mockDB.On("GetUser", testUserID).Return(&db.User{ID: testUserID, Login: "user", Role: auth.RoleMember}, nil)
+			mockDB.On("AddRefreshToken", testUserID, mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).Return(nil)
+
+			body, _ := json.Marshal(map[string]string{
+				"current_password": "oldpassword1",
+				"new_password":     tt.newPassword,
+			})
+
+			req, err := http.NewRequest("POST", "/me/password", bytes.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+
+			rr := httptest.NewRecorder()
+			h.ChangePasswordHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+
+			if tt.expectedStatus == http.StatusCreated {
+				mockDB.AssertCalled(t, "RevokeUserRefreshTokens", testUserID)
+
+				var response struct {
+					Token string `json:"token"`
+				}
+				if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+					t.Fatal(err)
+				}
+				claims, err := auth.ValidateToken(response.Token)
+				if err != nil {
+					t.Fatal(err)
+				}
+				isRevoked, _ := revoked.IsRevoked(claims.ID, testUserID, claims.IssuedAt.Time)
+				if isRevoked {
+					t.Errorf("Expected new access token to outlive the password change")
+				}
+			} else {
+				mockDB.AssertNotCalled(t, "RevokeUserRefreshTokens", testUserID)
+			}
+		})
+	}
+}
+
+func TestRequestPasswordResetHandler(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	mockNotifier := &mocks.MockNotifier{}
+	h := &handler.Handler{DB: mockDB, Cache: &mocks.MockTaskCache{}, Notifier: mockNotifier}
+
+	tests := []struct {
+		name     string
+		login    string
+		user     *db.User
+		addError error
+		notified bool
+	}{
+		{
+			name:     "Existing user is notified",
+			login:    "user",
+			user:     &db.User{ID: 1, Login: "user"},
+			notified: true,
+		},
+		{
+			name:     "Unknown login looks the same",
+			login:    "nobody",
+			addError: db.ErrUserNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB.ExpectedCalls = nil
+			mockNotifier.ExpectedCalls = nil
+			mockNotifier.Calls = nil
+
+			var tokenHash string
+			mockDB.On("AddPasswordResetToken", tt.login, mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).
+				Run(func(args mock.Arguments) { tokenHash = args.String(1) }).
+				Return(tt.user, tt.addError)
+			mockNotifier.On("SendPasswordReset", tt.login, mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).Return(nil)
+
+			body, _ := json.Marshal(map[string]string{"login": tt.login})
+
+			req, err := http.NewRequest("POST", "/password/reset/request", bytes.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			h.RequestPasswordResetHandler(rr, req)
+
+			if rr.Code != http.StatusAccepted {
+				t.Errorf("Expected status %d, got %d", http.StatusAccepted, rr.Code)
+			}
+
+			if !tt.notified {
+				mockNotifier.AssertNotCalled(t, "SendPasswordReset", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+
+			mockNotifier.AssertNumberOfCalls(t, "SendPasswordReset", 1)
+			token := mockNotifier.Calls[0].Arguments.String(1)
+			if auth.HashResetToken(token) != tokenHash {
+				t.Errorf("Expected only the hash of the sent token to be stored")
+			}
+		})
+	}
+}
+
+func TestResetPasswordHandler(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	h := &handler.Handler{DB: mockDB, Cache: &mocks.MockTaskCache{}, Revoked: cache.NewMemoryRevocationList()}
+
+	tests := []struct {
+		name           string
+		token          string
+		newPassword    string
+		resetError     error
+		expectedStatus int
+	}{
+		{
+			name:           "Succesfully reset password",
+			token:          "valid",
+			newPassword:    "newpassword1",
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "Used or expired token",
+			token:          "used",
+			newPassword:    "newpassword1",
+			resetError:     db.ErrInvalidResetToken,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Weak new password",
+			token:          "valid",
+			newPassword:    "password",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB.ExpectedCalls = nil
+			mockDB.Calls = nil
+			mockDB.On("ResetPassword", auth.HashResetToken(tt.token), tt.newPassword).Return(testUserID, tt.resetError)
+			mockDB.On("RevokeUserRefreshTokens", testUserID).Return(nil)
+
+			body, _ := json.Marshal(map[string]string{"token": tt.token, "new_password": tt.newPassword})
+
+			req, err := http.NewRequest("POST", "/password/reset", bytes.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			h.ResetPasswordHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+
+			if tt.expectedStatus == http.StatusNoContent {
+				mockDB.AssertCalled(t, "RevokeUserRefreshTokens", testUserID)
+			}
+		})
+	}
+}
+
+func TestFileNotifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notifications.jsonl")
+	fn := notify.NewFileNotifier(path)
+
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	if err := fn.SendPasswordReset("user", "first", expiresAt); err != nil {
+		t.Fatal(err)
+	}
+	if err := fn.SendPasswordReset("user", "second", expiresAt); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var tokens []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var n struct {
+			Type  string `json:"type"`
+			Login string `json:"login"`
+			Token string `json:"token"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &n); err != nil {
+			t.Fatal(err)
+		}
+		if n.Type != "password_reset" || n.Login != "user" {
+			t.Errorf("Unexpected notification %+v", n)
+		}
+		tokens = append(tokens, n.Token)
+	}
+
+	if len(tokens) != 2 || tokens[0] != "first" || tokens[1] != "second" {
+		t.Errorf("Expected both notifications in order, got %v", tokens)
+	}
+}
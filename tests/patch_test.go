@@ -0,0 +1,344 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	bt "restapi/basic_types"
+	"restapi/handler"
+	"restapi/patch"
+	"restapi/tests/mocks"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func assertJSONEqual(t *testing.T, expected string, actual []byte) {
+	t.Helper()
+
+	var e, a interface{}
+	if err := json.Unmarshal([]byte(expected), &e); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(actual, &a); err != nil {
+		t.Fatal(err)
+	}
+	ej, _ := json.Marshal(e)
+	aj, _ := json.Marshal(a)
+	if !bytes.Equal(ej, aj) {
+		t.Errorf("Expected %s, got %s", ej, aj)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	// Examples from RFC 7396 appendix A.
+	tests := []struct {
+		doc, patch, expected string
+	}{
+		{doc: `{"a":"b"}`, patch: `{"a":"c"}`, expected: `{"a":"c"}`},
+		{doc: `{"a":"b"}`, patch: `{"b":"c"}`, expected: `{"a":"b","b":"c"}`},
+		{doc: `{"a":"b"}`, patch: `{"a":null}`, expected: `{}`},
+		{doc: `{"a":[{"b":"c"}]}`, patch: `{"a":[1]}`, expected: `{"a":[1]}`},
+		{doc: `{"a":{"b":"c"}}`, patch: `{"a":{"b":"d","c":null}}`, expected: `{"a":{"b":"d"}}`},
+		{doc: `["a","b"]`, patch: `["c","d"]`, expected: `["c","d"]`},
+		{doc: `{"a":"foo"}`, patch: `"bar"`, expected: `"bar"`},
+		{doc: `{"e":null}`, patch: `{"a":1}`, expected: `{"e":null,"a":1}`},
+	}
+
+	for _, tt := range tests {
+		result, err := patch.MergePatch([]byte(tt.doc), []byte(tt.patch))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertJSONEqual(t, tt.expected, result)
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	// Examples from RFC 6902 appendix A.
+	tests := []struct {
+		name, doc, patch, expected string
+		expectedError              error
+	}{
+		{
+			name:     "Add an object member",
+			doc:      `{"foo":"bar"}`,
+			patch:    `[{"op":"add","path":"/baz","value":"qux"}]`,
+			expected: `{"baz":"qux","foo":"bar"}`,
+		},
+		{
+			name:     "Add an array element",
+			doc:      `{"foo":["bar","baz"]}`,
+			patch:    `[{"op":"add","path":"/foo/1","value":"qux"}]`,
+			expected: `{"foo":["bar","qux","baz"]}`,
+		},
+		{
+			name:     "Remove an array element",
+			doc:      `{"foo":["bar","qux","baz"]}`,
+			patch:    `[{"op":"remove","path":"/foo/1"}]`,
+			expected: `{"foo":["bar","baz"]}`,
+		},
+		{
+			name:     "Replace a value",
+			doc:      `{"baz":"qux","foo":"bar"}`,
+			patch:    `[{"op":"replace","path":"/baz","value":"boo"}]`,
+			expected: `{"baz":"boo","foo":"bar"}`,
+		},
+		{
+			name:     "Move a value",
+			doc:      `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			patch:    `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			expected: `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`,
+		},
+		{
+			name:     "Move an array element",
+			doc:      `{"foo":["all","grass","cows","eat"]}`,
+			patch:    `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			expected: `{"foo":["all","cows","eat","grass"]}`,
+		},
+		{
+			name:     "Copy a value",
+			doc:      `{"foo":{"bar":1}}`,
+			patch:    `[{"op":"copy","from":"/foo","path":"/baz"},{"op":"replace","path":"/baz/bar","value":2}]`,
+			expected: `{"foo":{"bar":1},"baz":{"bar":2}}`,
+		},
+		{
+			name:     "Test a value: success",
+			doc:      `{"baz":"qux","foo":["a",2,"c"]}`,
+			patch:    `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`,
+			expected: `{"baz":"qux","foo":["a",2,"c"]}`,
+		},
+		{
+			name:          "Test a value: error",
+			doc:           `{"baz":"qux"}`,
+			patch:         `[{"op":"test","path":"/baz","value":"bar"}]`,
+			expectedError: patch.ErrTestFailed,
+		},
+		{
+			name:          "Add to a nonexistent target",
+			doc:           `{"foo":"bar"}`,
+			patch:         `[{"op":"add","path":"/baz/bat","value":"qux"}]`,
+			expectedError: patch.ErrPathNotFound,
+		},
+		{
+			name:     "Escape ordering",
+			doc:      `{"/":9,"~1":10}`,
+			patch:    `[{"op":"test","path":"/~01","value":10}]`,
+			expected: `{"/":9,"~1":10}`,
+		},
+		{
+			name:     "Add an array value",
+			doc:      `{"foo":["bar"]}`,
+			patch:    `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`,
+			expected: `{"foo":["bar",["abc","def"]]}`,
+		},
+		{
+			name:          "Failed operation leaves nothing applied",
+			doc:           `{"foo":"bar"}`,
+			patch:         `[{"op":"add","path":"/baz","value":1},{"op":"remove","path":"/missing"}]`,
+			expectedError: patch.ErrPathNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := patch.DecodeJSONPatch([]byte(tt.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := p.Apply([]byte(tt.doc))
+			if tt.expectedError != nil {
+				if !errors.Is(err, tt.expectedError) {
+					t.Errorf("Expected error %v, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertJSONEqual(t, tt.expected, result)
+		})
+	}
+
+	if _, err := patch.DecodeJSONPatch([]byte(`[{"op":"add","path":"/a"}]`)); !errors.Is(err, patch.ErrInvalidPatch) {
+		t.Errorf("Expected add without value to be rejected, got %v", err)
+	}
+	if _, err := patch.DecodeJSONPatch([]byte(`[{"op":"rename","path":"/a"}]`)); !errors.Is(err, patch.ErrInvalidPatch) {
+		t.Errorf("Expected unknown operation to be rejected, got %v", err)
+	}
+}
+
+func TestPatchTaskHandler(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	mockCache := &mocks.MockTaskCache{}
+	h := &handler.Handler{DB: mockDB, Cache: mockCache}
+
+	tests := []struct {
+		name           string
+		currentStatus  string
+		ifMatch        string
+		contentType    string
+		body           string
+		expectedStatus int
+		expectedTask   bt.Task
+	}{
+		{
+			name:           "Merge patch changes one field",
+			contentType:    "application/merge-patch+json",
+			body:           `{"description": "New description", "id": 99}`,
+			expectedStatus: http.StatusOK,
+			expectedTask:   bt.Task{ID: 1, Name: "Test Task", Description: "New description", Status: bt.StatusTodo, Priority: 2, Tags: []string{}, Version: 2},
+		},
+		{
+			name:           "Merge patch with null clears an optional field",
+			contentType:    "application/merge-patch+json",
+			body:           `{"priority": null, "status": "in_progress"}`,
+			expectedStatus: http.StatusOK,
+			expectedTask:   bt.Task{ID: 1, Name: "Test Task", Description: "Test Description", Status: bt.StatusInProgress, Tags: []string{}, Version: 2},
+		},
+		{
+			name:           "JSON patch guarded by test",
+			contentType:    "application/json-patch+json",
+			body:           `[{"op": "test", "path": "/name", "value": "Test Task"}, {"op": "replace", "path": "/name", "value": "Renamed"}]`,
+			expectedStatus: http.StatusOK,
+			expectedTask:   bt.Task{ID: 1, Name: "Renamed", Description: "Test Description", Status: bt.StatusTodo, Priority: 2, Tags: []string{}, Version: 2},
+		},
+		{
+			name:           "Matching If-Match",
+			ifMatch:        `"1"`,
+			contentType:    "application/merge-patch+json",
+			body:           `{"priority": 5}`,
+			expectedStatus: http.StatusOK,
+			expectedTask:   bt.Task{ID: 1, Name: "Test Task", Description: "Test Description", Status: bt.StatusTodo, Priority: 5, Tags: []string{}, Version: 2},
+		},
+		{
+			name:           "If-Match listing the current version",
+			ifMatch:        `"7", W/"1", "1"`,
+			contentType:    "application/merge-patch+json",
+			body:           `{"priority": 5}`,
+			expectedStatus: http.StatusOK,
+			expectedTask:   bt.Task{ID: 1, Name: "Test Task", Description: "Test Description", Status: bt.StatusTodo, Priority: 5, Tags: []string{}, Version: 2},
+		},
+		{
+			name:           "Merge patch replaces tags",
+			contentType:    "application/merge-patch+json",
+			body:           `{"tags": ["work", " home ", "work"]}`,
+			expectedStatus: http.StatusOK,
+			expectedTask:   bt.Task{ID: 1, Name: "Test Task", Description: "Test Description", Status: bt.StatusTodo, Priority: 2, Tags: []string{"home", "work"}, Version: 2},
+		},
+		{
+			name:           "Stale If-Match",
+			ifMatch:        `"7"`,
+			contentType:    "application/merge-patch+json",
+			body:           `{"priority": 5}`,
+			expectedStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:           "If-Match listing only a weak current version",
+			ifMatch:        `"7", W/"1"`,
+			contentType:    "application/merge-patch+json",
+			body:           `{"priority": 5}`,
+			expectedStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:           "Failed test operation",
+			contentType:    "application/json-patch+json",
+			body:           `[{"op": "test", "path": "/name", "value": "Stale"}, {"op": "replace", "path": "/name", "value": "Renamed"}]`,
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "Missing path",
+			contentType:    "application/json-patch+json",
+			body:           `[{"op": "replace", "path": "/title", "value": "Renamed"}]`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "Removing a required field",
+			contentType:    "application/json-patch+json",
+			body:           `[{"op": "remove", "path": "/name"}]`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Wrong type",
+			contentType:    "application/merge-patch+json",
+			body:           `{"priority": "high"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "Illegal status transition",
+			currentStatus:  bt.StatusDone,
+			contentType:    "application/merge-patch+json",
+			body:           `{"status": "in_progress"}`,
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "Malformed JSON patch",
+			contentType:    "application/json-patch+json",
+			body:           `{"op": "add"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Unsupported content type",
+			contentType:    "application/json",
+			body:           `{"name": "Renamed"}`,
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current := &bt.Task{ID: 1, OwnerID: testUserID, Name: "Test Task", Description: "Test Description",
+				Status: bt.StatusTodo, Priority: 2, Version: 1}
+			if tt.currentStatus != "" {
+				current.Status = tt.currentStatus
+			}
+
+			mockDB.ExpectedCalls = nil
+			mockDB.On("PatchTask", testUserID, 1, testUserID).Return(current, nil)
+			mockCache.ExpectedCalls = nil
+			mockCache.Calls = nil
+			mockCache.On("Delete", testUserID, 1).Return(nil)
+
+			req, err := http.NewRequest("PATCH", "/tasks/1", bytes.NewReader([]byte(tt.body)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", tt.contentType)
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+			req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+			rr := httptest.NewRecorder()
+			h.PatchTaskHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+
+			if tt.expectedStatus != http.StatusOK {
+				mockCache.AssertNotCalled(t, "Delete", testUserID, 1)
+				return
+			}
+
+			mockCache.AssertCalled(t, "Delete", testUserID, 1)
+			if etag := rr.Header().Get("ETag"); etag != `"2"` {
+				t.Errorf("Expected ETag \"2\", got %s", etag)
+			}
+
+			var responseTask bt.Task
+			if err := json.NewDecoder(rr.Body).Decode(&responseTask); err != nil {
+				t.Fatal(err)
+			}
+			responseTask.StartedAt = nil
+			if !reflect.DeepEqual(responseTask, tt.expectedTask) {
+				t.Errorf("Expected task %+v, got %+v", tt.expectedTask, responseTask)
+			}
+		})
+	}
+}
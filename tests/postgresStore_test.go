@@ -0,0 +1,143 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	bt "restapi/basic_types"
+	db "restapi/db"
+	"sync"
+	"testing"
+	"time"
+)
+
+// requirePostgres skips the test unless SQL_HOST points it at a database
+// with schema.sql applied.
+func requirePostgres(t *testing.T) {
+	t.Helper()
+	if os.Getenv("SQL_HOST") == "" {
+		t.Skip("SQL_HOST is not set, skipping PostgreSQL test")
+	}
+}
+
+// newPostgresStore connects to the database described by the SQL_*
+// variables.
+func newPostgresStore(t *testing.T) *db.PostgresStore {
+	t.Helper()
+	requirePostgres(t)
+
+	store, err := db.NewPostgresStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestCompleteTaskWhileChangingLinks(t *testing.T) {
+	store := newPostgresStore(t)
+
+	user, err := store.CreateUser(&db.UserData{Login: fmt.Sprintf("links-%d", time.Now().UnixNano()), Password: "password1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tasks := make([]*bt.Task, 3)
+	for i := range tasks {
+		tasks[i] = &bt.Task{OwnerID: user.ID, Name: fmt.Sprintf("Task %d", i+1)}
+		if err := store.CreateTask(tasks[i], user.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	task, parent, blocker := tasks[0].ID, tasks[1].ID, tasks[2].ID
+
+	// Completing the task locks its row, while changing its links locks the
+	// row after the link lock; taking the two in different orders deadlocks.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			for _, status := range []string{bt.StatusDone, bt.StatusTodo} {
+				_, err := store.PatchTask(user.ID, task, user.ID, func(current *bt.Task) error {
+					current.Status = status
+					return nil
+				})
+				if err != nil && !errors.Is(err, db.ErrTaskBlocked) && !errors.Is(err, db.ErrInvalidTransition) {
+					t.Errorf("Failed to set status %s: %v", status, err)
+				}
+			}
+		}
+	}()
+
+	for i := 0; i < 50; i++ {
+		if _, err := store.SetTaskParent(user.ID, task, &parent, user.ID); err != nil {
+			t.Errorf("Failed to set parent: %v", err)
+		}
+		if _, err := store.SetTaskParent(user.ID, task, nil, user.ID); err != nil {
+			t.Errorf("Failed to clear parent: %v", err)
+		}
+		if err := store.AddTaskBlocker(user.ID, task, blocker); err != nil {
+			t.Errorf("Failed to add blocker: %v", err)
+		}
+		if err := store.RemoveTaskBlocker(user.ID, task, blocker); err != nil {
+			t.Errorf("Failed to remove blocker: %v", err)
+		}
+	}
+	wg.Wait()
+}
+
+func TestCreateTaskAfterChosenIDs(t *testing.T) {
+	store := newPostgresStore(t)
+
+	user, err := store.CreateUser(&db.UserData{Login: fmt.Sprintf("ids-%d", time.Now().UnixNano()), Password: "password1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := &bt.Task{OwnerID: user.ID, Name: "First"}
+	if err := store.CreateTask(first, user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// A run of chosen IDs longer than CreateTask retries must not make the
+	// identity sequence collide with them.
+	for id := first.ID + 1; id <= first.ID+10; id++ {
+		if err := store.AddTask(&bt.Task{ID: id, OwnerID: user.ID, Name: "Chosen"}, user.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	task := &bt.Task{OwnerID: user.ID, Name: "Next"}
+	if err := store.CreateTask(task, user.ID); err != nil {
+		t.Fatalf("Failed to create task after chosen IDs: %v", err)
+	}
+	if task.ID <= first.ID+10 {
+		t.Errorf("Expected an ID after %d, got %d", first.ID+10, task.ID)
+	}
+}
+
+func TestInvalidSearchLanguage(t *testing.T) {
+	requirePostgres(t)
+	t.Setenv("TASK_SEARCH_LANGUAGE", "englsh")
+
+	if _, err := db.NewPostgresStore(); err == nil {
+		t.Error("Expected an error for an unknown search language")
+	}
+}
+
+func TestGetAllAPIKeysWithoutKeys(t *testing.T) {
+	store := newPostgresStore(t)
+
+	user, err := store.CreateUser(&db.UserData{Login: fmt.Sprintf("keys-%d", time.Now().UnixNano()), Password: "password1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := store.GetAllAPIKeys(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys == nil || len(keys) != 0 {
+		t.Errorf("Expected an empty, non-nil key list, got %#v", keys)
+	}
+}
@@ -0,0 +1,255 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	bt "restapi/basic_types"
+	db "restapi/db"
+	"restapi/handler"
+	"restapi/tests/mocks"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCreateProjectHandler(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	h := &handler.Handler{DB: mockDB, Cache: &mocks.MockTaskCache{}}
+
+	mockDB.On("CreateProject", mock.MatchedBy(func(project *bt.Project) bool {
+		return project.OwnerID == testUserID && project.Name == "Home"
+	})).Run(func(args mock.Arguments) {
+		args.Get(0).(*bt.Project).ID = 4
+	}).Return(nil)
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{name: "Succesfully create project", body: `{"name": " Home ", "description": "Chores", "id": 99}`, expectedStatus: http.StatusCreated},
+		{name: "Missing name", body: `{"description": "Chores"}`, expectedStatus: http.StatusBadRequest},
+		{name: "Malformed JSON", body: `{"name":`, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", "/projects", bytes.NewBufferString(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+
+			rr := httptest.NewRecorder()
+			h.CreateProjectHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if tt.expectedStatus == http.StatusCreated && rr.Header().Get("Location") != "/projects/4" {
+				t.Errorf("Expected Location /projects/4, got %q", rr.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestUpdateProjectHandler(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	h := &handler.Handler{DB: mockDB, Cache: &mocks.MockTaskCache{}}
+
+	mockDB.On("UpdateProject", &bt.Project{ID: 4, OwnerID: testUserID, Name: "Garden"}).
+		Return(&bt.Project{ID: 4, Name: "Garden"}, nil)
+	mockDB.On("UpdateProject", &bt.Project{ID: 5, OwnerID: testUserID, Name: "Garden"}).
+		Return((*bt.Project)(nil), db.ErrProjectNotFound)
+
+	tests := []struct {
+		name           string
+		id             string
+		expectedStatus int
+	}{
+		{name: "Succesfully update project", id: "4", expectedStatus: http.StatusOK},
+		{name: "Project not found", id: "5", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("PUT", "/projects/"+tt.id, bytes.NewBufferString(`{"name": "Garden"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+			req = mux.SetURLVars(req, map[string]string{"projectID": tt.id})
+
+			rr := httptest.NewRecorder()
+			h.UpdateProjectHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestDeleteProjectHandler(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	mockCache := &mocks.MockTaskCache{}
+	h := &handler.Handler{DB: mockDB, Cache: mockCache}
+
+	mockDB.On("DeleteProject", testUserID, 4, false, testUserID).Return([]int{1, 2}, nil)
+	mockDB.On("DeleteProject", testUserID, 5, true, testUserID).Return([]int{3}, nil)
+	mockDB.On("DeleteProject", testUserID, 6, false, testUserID).Return([]int(nil), db.ErrProjectNotFound)
+	mockCache.On("Delete", testUserID, mock.Anything).Return(nil)
+
+	tests := []struct {
+		name           string
+		id             string
+		rawQuery       string
+		expectedStatus int
+	}{
+		{name: "Reassign tasks by default", id: "4", expectedStatus: http.StatusNoContent},
+		{name: "Cascade to tasks", id: "5", rawQuery: "tasks=cascade", expectedStatus: http.StatusNoContent},
+		{name: "Project not found", id: "6", rawQuery: "tasks=reassign", expectedStatus: http.StatusNotFound},
+		{name: "Unknown mode", id: "4", rawQuery: "tasks=keep", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("DELETE", "/projects/"+tt.id+"?"+tt.rawQuery, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+			req = mux.SetURLVars(req, map[string]string{"projectID": tt.id})
+
+			rr := httptest.NewRecorder()
+			h.DeleteProjectHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+		})
+	}
+
+	mockCache.AssertNumberOfCalls(t, "Delete", 3)
+}
+
+func TestProjectTasksHandlers(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	h := &handler.Handler{DB: mockDB, Cache: &mocks.MockTaskCache{}}
+
+	mockDB.On("GetProject", testUserID, 4).Return(&bt.Project{ID: 4, Name: "Home"}, nil)
+	mockDB.On("GetProject", testUserID, 5).Return((*bt.Project)(nil), db.ErrProjectNotFound)
+	mockDB.On("GetAllTasks", mock.MatchedBy(func(query *db.TaskQuery) bool {
+		return query.OwnerID == testUserID && query.ProjectID == 4 && !query.Deleted
+	})).Return([]bt.Task{{ID: 1, Name: "Dishes"}}, "", nil)
+	mockDB.On("CreateTask", mock.MatchedBy(func(task *bt.Task) bool {
+		return task.ProjectID != nil && *task.ProjectID == 4
+	}), testUserID).Run(func(args mock.Arguments) {
+		args.Get(0).(*bt.Task).ID = 17
+	}).Return(nil)
+	mockDB.On("CreateTask", mock.MatchedBy(func(task *bt.Task) bool {
+		return task.ProjectID != nil && *task.ProjectID == 5
+	}), testUserID).Return(db.ErrProjectNotFound)
+
+	t.Run("List tasks of a project", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/projects/4/tasks", nil)
+		req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+		req = mux.SetURLVars(req, map[string]string{"projectID": "4"})
+
+		rr := httptest.NewRecorder()
+		h.GetProjectTasksHandler(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+		var page struct {
+			Tasks []bt.Task `json:"tasks"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Tasks) != 1 || page.Tasks[0].ID != 1 {
+			t.Errorf("Unexpected tasks %v", page.Tasks)
+		}
+	})
+
+	t.Run("List tasks of a missing project", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/projects/5/tasks", nil)
+		req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+		req = mux.SetURLVars(req, map[string]string{"projectID": "5"})
+
+		rr := httptest.NewRecorder()
+		h.GetProjectTasksHandler(rr, req)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	tests := []struct {
+		name             string
+		path             string
+		projectID        string
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{name: "Create task in a project", path: "/projects/4/tasks", projectID: "4",
+			expectedStatus: http.StatusCreated, expectedLocation: "/tasks/17"},
+		{name: "Create task for another user", path: "/users/9/projects/4/tasks", projectID: "4",
+			expectedStatus: http.StatusCreated, expectedLocation: "/users/9/tasks/17"},
+		{name: "Create task in a missing project", path: "/projects/5/tasks", projectID: "5",
+			expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"name": "Dishes", "description": "After dinner", "project_id": 9}`
+			req, _ := http.NewRequest("POST", tt.path, bytes.NewBufferString(body))
+			req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+			req = mux.SetURLVars(req, map[string]string{"projectID": tt.projectID})
+
+			rr := httptest.NewRecorder()
+			h.CreateTaskHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if location := rr.Header().Get("Location"); location != tt.expectedLocation {
+				t.Errorf("Expected Location %q, got %q", tt.expectedLocation, location)
+			}
+		})
+	}
+}
+
+func TestMoveTaskOutOfProject(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	mockCache := &mocks.MockTaskCache{}
+	h := &handler.Handler{DB: mockDB, Cache: mockCache}
+
+	current := &bt.Task{ID: 1, OwnerID: testUserID, Name: "Dishes", Description: "After dinner",
+		Status: bt.StatusTodo, ProjectID: intPtr(4), Version: 1}
+	mockDB.On("PatchTask", testUserID, 1, testUserID).Return(current, nil)
+	mockCache.On("Delete", testUserID, 1).Return(nil)
+
+	req, _ := http.NewRequest("PATCH", "/tasks/1", bytes.NewBufferString(`{"project_id": null}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+	rr := httptest.NewRecorder()
+	h.PatchTaskHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	var got map[string]interface{}
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got["project_id"]; ok {
+		t.Errorf("Expected the task to leave its project, got project_id %v", got["project_id"])
+	}
+}
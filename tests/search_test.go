@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	bt "restapi/basic_types"
+	db "restapi/db"
+	"restapi/handler"
+	"restapi/tests/mocks"
+	"testing"
+)
+
+var searchCorpus = []bt.Task{
+	{ID: 1, Name: "Buy groceries", Description: "Milk, bread and coffee"},
+	{ID: 2, Name: "Write report", Description: "Quarterly report about coffee sales"},
+	{ID: 3, Name: "Coffee machine", Description: "Descale the coffee machine"},
+}
+
+func TestSearchTasksHandler(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	h := &handler.Handler{DB: mockDB, Cache: &mocks.MockTaskCache{}}
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedIDs    []int
+	}{
+		{
+			name:           "Name matches rank first",
+			query:          "coffee",
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []int{3, 1, 2},
+		},
+		{
+			name:           "Prefix matching of every term",
+			query:          "rep coff",
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []int{2},
+		},
+		{
+			name:           "No matches",
+			query:          "holiday",
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []int{},
+		},
+		{
+			name:           "Missing query",
+			query:          "  ",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB.ExpectedCalls = nil
+			mockDB.On("SearchTasks", testUserID, tt.query, db.DefaultTaskPageSize).Return(searchCorpus, nil)
+
+			req, err := http.NewRequest("GET", "/tasks/search?q="+url.QueryEscape(tt.query), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+
+			rr := httptest.NewRecorder()
+			h.SearchTasksHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+
+			if tt.expectedStatus == http.StatusOK {
+				var results []db.TaskSearchResult
+				if err := json.NewDecoder(rr.Body).Decode(&results); err != nil {
+					t.Fatal(err)
+				}
+
+				ids := []int{}
+				for _, result := range results {
+					ids = append(ids, result.ID)
+				}
+				if len(ids) != len(tt.expectedIDs) {
+					t.Fatalf("Expected tasks %v, got %v", tt.expectedIDs, ids)
+				}
+				for i := range ids {
+					if ids[i] != tt.expectedIDs[i] {
+						t.Errorf("Expected tasks %v, got %v", tt.expectedIDs, ids)
+						break
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package tests
+
+import (
+	bt "restapi/basic_types"
+	"testing"
+	"time"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		allowed  bool
+	}{
+		{from: bt.StatusTodo, to: bt.StatusInProgress, allowed: true},
+		{from: bt.StatusInProgress, to: bt.StatusDone, allowed: true},
+		{from: bt.StatusDone, to: bt.StatusTodo, allowed: true},
+		{from: bt.StatusDone, to: bt.StatusDone, allowed: true},
+		{from: bt.StatusDone, to: bt.StatusInProgress, allowed: false},
+		{from: bt.StatusCancelled, to: bt.StatusDone, allowed: false},
+		{from: bt.StatusBlocked, to: bt.StatusDone, allowed: false},
+	}
+
+	for _, tt := range tests {
+		if got := bt.CanTransition(tt.from, tt.to); got != tt.allowed {
+			t.Errorf("CanTransition(%s, %s) = %v, expected %v", tt.from, tt.to, got, tt.allowed)
+		}
+	}
+}
+
+func TestSetTransitions(t *testing.T) {
+	t.Cleanup(func() { bt.SetTransitions(nil) })
+
+	table, err := bt.ParseTransitions("todo:done; done:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bt.SetTransitions(table); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bt.CanTransition(bt.StatusTodo, bt.StatusDone) {
+		t.Errorf("Expected todo -> done to be allowed")
+	}
+	if bt.CanTransition(bt.StatusTodo, bt.StatusInProgress) {
+		t.Errorf("Expected todo -> in_progress to be forbidden by the custom table")
+	}
+	if bt.CanTransition(bt.StatusDone, bt.StatusTodo) {
+		t.Errorf("Expected done to be final in the custom table")
+	}
+
+	if _, err := bt.ParseTransitions("todo"); err == nil {
+		t.Errorf("Expected malformed rule to be rejected")
+	}
+	table, _ = bt.ParseTransitions("todo:finished")
+	if err := bt.SetTransitions(table); err == nil {
+		t.Errorf("Expected unknown status to be rejected")
+	}
+}
+
+func TestApplyStatus(t *testing.T) {
+	started := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	finished := started.Add(time.Hour)
+
+	task := &bt.Task{}
+	task.ApplyStatus(bt.StatusTodo, started)
+	if task.StartedAt != nil || task.CompletedAt != nil {
+		t.Fatalf("Expected no timestamps for a new task, got %+v", task)
+	}
+
+	task.ApplyStatus(bt.StatusInProgress, started)
+	task.ApplyStatus(bt.StatusBlocked, finished)
+	task.ApplyStatus(bt.StatusInProgress, finished)
+	if task.StartedAt == nil || !task.StartedAt.Equal(started) {
+		t.Errorf("Expected started_at to keep the first start, got %v", task.StartedAt)
+	}
+
+	task.ApplyStatus(bt.StatusDone, finished)
+	if task.CompletedAt == nil || !task.CompletedAt.Equal(finished) {
+		t.Errorf("Expected completed_at %v, got %v", finished, task.CompletedAt)
+	}
+
+	task.ApplyStatus(bt.StatusTodo, finished)
+	if task.CompletedAt != nil {
+		t.Errorf("Expected completed_at to be cleared on reopen, got %v", task.CompletedAt)
+	}
+}
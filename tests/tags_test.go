@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	bt "restapi/basic_types"
+	db "restapi/db"
+	"restapi/handler"
+	"restapi/tests/mocks"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tags, err := bt.NormalizeTags([]string{"work", " home", "work ", "Work"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tags, []string{"Work", "home", "work"}) {
+		t.Errorf("Unexpected tags %v", tags)
+	}
+
+	if tags, err := bt.NormalizeTags(nil); err != nil || tags != nil {
+		t.Errorf("Expected nil tags to stay nil, got %v, %v", tags, err)
+	}
+	if _, err := bt.NormalizeTags([]string{" "}); err == nil {
+		t.Errorf("Expected empty tag to be rejected")
+	}
+	if _, err := bt.NormalizeTags([]string{strings.Repeat("x", bt.MaxTagLength+1)}); err == nil {
+		t.Errorf("Expected long tag to be rejected")
+	}
+
+	many := make([]string, bt.MaxTagsPerTask+1)
+	for i := range many {
+		many[i] = strings.Repeat("x", i+1)
+	}
+	if _, err := bt.NormalizeTags(many); err == nil {
+		t.Errorf("Expected too many tags to be rejected")
+	}
+}
+
+func TestGetTagsHandler(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	h := &handler.Handler{DB: mockDB, Cache: &mocks.MockTaskCache{}}
+
+	expected := []db.TagCount{{Name: "home", Count: 1}, {Name: "work", Count: 3}}
+	mockDB.On("GetTags", testUserID).Return(expected, nil)
+
+	req, err := http.NewRequest("GET", "/tags", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+
+	rr := httptest.NewRecorder()
+	h.GetTagsHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	var tags []db.TagCount
+	if err := json.NewDecoder(rr.Body).Decode(&tags); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tags, expected) {
+		t.Errorf("Expected tags %v, got %v", expected, tags)
+	}
+}
+
+func TestChangeTaskTagsHandlers(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	mockCache := &mocks.MockTaskCache{}
+	h := &handler.Handler{DB: mockDB, Cache: mockCache}
+
+	current := &bt.Task{ID: 1, Name: "Test Task", Description: "Test Description", Status: bt.StatusTodo,
+		Tags: []string{"home", "work"}, Version: 1}
+	mockDB.On("PatchTask", testUserID, 1, testUserID).Return(current, nil)
+	mockDB.On("PatchTask", testUserID, 2, testUserID).Return((*bt.Task)(nil), db.ErrTaskNotFound)
+	mockCache.On("Delete", testUserID, 1).Return(nil)
+
+	tests := []struct {
+		name           string
+		remove         bool
+		id             string
+		tag            string
+		expectedStatus int
+		expectedTags   []string
+	}{
+		{name: "Add a tag", id: "1", tag: " urgent ", expectedStatus: http.StatusOK, expectedTags: []string{"home", "urgent", "work"}},
+		{name: "Add an existing tag", id: "1", tag: "work", expectedStatus: http.StatusOK, expectedTags: []string{"home", "work"}},
+		{name: "Remove a tag", remove: true, id: "1", tag: "home", expectedStatus: http.StatusOK, expectedTags: []string{"work"}},
+		{name: "Remove a missing tag", remove: true, id: "1", tag: "urgent", expectedStatus: http.StatusNotFound},
+		{name: "Empty tag", id: "1", tag: " ", expectedStatus: http.StatusUnprocessableEntity},
+		{name: "Task not found", id: "2", tag: "work", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method, handle := "PUT", h.AddTaskTagHandler
+			if tt.remove {
+				method, handle = "DELETE", h.RemoveTaskTagHandler
+			}
+
+			req, err := http.NewRequest(method, "/tasks/"+tt.id+"/tags/"+tt.tag, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+			req = mux.SetURLVars(req, map[string]string{"id": tt.id, "tag": tt.tag})
+
+			rr := httptest.NewRecorder()
+			handle(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var task bt.Task
+			if err := json.NewDecoder(rr.Body).Decode(&task); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(task.Tags, tt.expectedTags) {
+				t.Errorf("Expected tags %v, got %v", tt.expectedTags, task.Tags)
+			}
+		})
+	}
+
+	if !reflect.DeepEqual(current.Tags, []string{"home", "work"}) {
+		t.Errorf("Current task was modified: %v", current.Tags)
+	}
+}
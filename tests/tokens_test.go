@@ -0,0 +1,196 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"restapi/auth"
+	"restapi/cache"
+	db "restapi/db"
+	"restapi/handler"
+	"restapi/tests/mocks"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRefreshTokenHandler(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	mockCache := &mocks.MockTaskCache{}
+	h := &handler.Handler{DB: mockDB, Cache: mockCache}
+
+	tests := []struct {
+		name           string
+		refreshToken   string
+		mockUserID     int
+		mockRotateErr  error
+		expectedStatus int
+	}{
+		{
+			name:           "Succesfully refresh token",
+			refreshToken:   "valid-token",
+			mockUserID:     1,
+			mockRotateErr:  nil,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Unknown refresh token",
+			refreshToken:   "unknown-token",
+			mockUserID:     -1,
+			mockRotateErr:  db.ErrRefreshTokenNotFound,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Expired refresh token",
+			refreshToken:   "expired-token",
+			mockUserID:     -1,
+			mockRotateErr:  db.ErrRefreshTokenExpired,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Reused refresh token",
+			refreshToken:   "used-token",
+			mockUserID:     -1,
+			mockRotateErr:  db.ErrRefreshTokenReused,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Missing refresh token",
+			refreshToken:   "",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB.ExpectedCalls = nil
+			mockDB.On("RotateRefreshToken", auth.HashRefreshToken(tt.refreshToken),
+				mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).Return(tt.mockUserID, tt.mockRotateErr)
+			mockDB.On("GetUser", tt.mockUserID).Return(&db.User{ID: tt.mockUserID, Role: "member"}, nil)
+
+			body, _ := json.Marshal(map[string]string{"refresh_token": tt.refreshToken})
+
+			req, err := http.NewRequest("POST", "/token/refresh", bytes.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			h.RefreshTokenHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+
+			if tt.expectedStatus == http.StatusOK {
+				var response struct {
+					Token        string `json:"token"`
+					RefreshToken string `json:"refresh_token"`
+				}
+				if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+					t.Fatal(err)
+				}
+				if response.Token == "" || response.RefreshToken == "" || response.RefreshToken == tt.refreshToken {
+					t.Errorf("Expected rotated tokens in response, got %v", response)
+				}
+			}
+		})
+	}
+}
+
+func TestLogoutHandlers(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	revoked := cache.NewMemoryRevocationList()
+	h := &handler.Handler{DB: mockDB, Cache: &mocks.MockTaskCache{}, Revoked: revoked}
+
+	authorize := func(token string) int {
+		req, _ := http.NewRequest("GET", "/tasks", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		h.AuthorizationMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	t.Run("Logout revokes current token and refresh token", func(t *testing.T) {
+		token, _ := auth.GenerateToken(testUserID, auth.RoleMember)
+		other, _ := auth.GenerateToken(testUserID, auth.RoleMember)
+		claims, _ := auth.ValidateToken(token)
+
+		mockDB.ExpectedCalls = nil
+		mockDB.On("RevokeRefreshToken", auth.HashRefreshToken("refresh")).Return(nil)
+
+		body, _ := json.Marshal(map[string]string{"refresh_token": "refresh"})
+		req, _ := http.NewRequest("POST", "/logout", bytes.NewReader(body))
+		req = req.WithContext(handler.WithClaims(req.Context(), claims))
+
+		rr := httptest.NewRecorder()
+		h.LogoutHandler(rr, req)
+
+		if rr.Code != http.StatusNoContent {
+			t.Errorf("Expected status %d, got %d", http.StatusNoContent, rr.Code)
+		}
+		mockDB.AssertCalled(t, "RevokeRefreshToken", auth.HashRefreshToken("refresh"))
+
+		if code := authorize(token); code != http.StatusUnauthorized {
+			t.Errorf("Expected logged out token to be rejected, got %d", code)
+		}
+		if code := authorize(other); code != http.StatusOK {
+			t.Errorf("Expected other session to stay valid, got %d", code)
+		}
+	})
+
+	t.Run("Logout from all sessions", func(t *testing.T) {
+		token, _ := auth.GenerateToken(testUserID, auth.RoleMember)
+		otherUserToken, _ := auth.GenerateToken(testUserID+1, auth.RoleMember)
+
+		mockDB.ExpectedCalls = nil
+		mockDB.On("RevokeUserRefreshTokens", testUserID).Return(nil)
+
+		req, _ := http.NewRequest("POST", "/logout/all", nil)
+		req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+
+		rr := httptest.NewRecorder()
+		h.LogoutAllHandler(rr, req)
+
+		if rr.Code != http.StatusNoContent {
+			t.Errorf("Expected status %d, got %d", http.StatusNoContent, rr.Code)
+		}
+		mockDB.AssertCalled(t, "RevokeUserRefreshTokens", testUserID)
+
+		if code := authorize(token); code != http.StatusUnauthorized {
+			t.Errorf("Expected revoked session to be rejected, got %d", code)
+		}
+		if code := authorize(otherUserToken); code != http.StatusOK {
+			t.Errorf("Expected other user's session to stay valid, got %d", code)
+		}
+
+		time.Sleep(time.Millisecond)
+		newToken, _ := auth.GenerateToken(testUserID, auth.RoleMember)
+		if code := authorize(newToken); code != http.StatusOK {
+			t.Errorf("Expected token issued after revocation to be valid, got %d", code)
+		}
+	})
+
+	t.Run("API keys cannot log out", func(t *testing.T) {
+		apiKey, _ := auth.GenerateAPIKey()
+
+		mockDB.ExpectedCalls, mockDB.Calls = nil, nil
+		mockDB.On("AuthenticateAPIKey", auth.HashAPIKey(apiKey)).
+			Return(&db.APIKey{ID: 1, UserID: testUserID, Scopes: []string{"tasks:read"}}, auth.RoleMember, nil)
+
+		for path, logout := range map[string]http.HandlerFunc{"/logout": h.LogoutHandler, "/logout/all": h.LogoutAllHandler} {
+			req, _ := http.NewRequest("POST", path, nil)
+			req.Header.Set("X-API-Key", apiKey)
+
+			rr := httptest.NewRecorder()
+			h.AuthorizationMiddleware(logout).ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusForbidden {
+				t.Errorf("Expected status %d for %s, got %d", http.StatusForbidden, path, rr.Code)
+			}
+		}
+		mockDB.AssertNotCalled(t, "RevokeUserRefreshTokens", testUserID)
+	})
+}
@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	bt "restapi/basic_types"
+	"restapi/blob"
+	db "restapi/db"
+	"restapi/handler"
+	"restapi/tests/mocks"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetTrashHandler(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	h := &handler.Handler{DB: mockDB, Cache: &mocks.MockTaskCache{}}
+
+	mockDB.On("GetAllTasks", mock.MatchedBy(func(query *db.TaskQuery) bool {
+		return query.OwnerID == testUserID && query.Deleted
+	})).Return([]bt.Task{}, "", nil)
+
+	req, err := http.NewRequest("GET", "/trash", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+
+	rr := httptest.NewRecorder()
+	h.GetTrashHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	mockDB.AssertNumberOfCalls(t, "GetAllTasks", 1)
+}
+
+func TestRestoreTaskHandler(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	h := &handler.Handler{DB: mockDB, Cache: &mocks.MockTaskCache{}}
+
+	mockDB.On("RestoreTask", testUserID, 1, testUserID).Return(&bt.Task{ID: 1, Name: "Test Task", Version: 3}, nil)
+	mockDB.On("RestoreTask", testUserID, 2, testUserID).Return((*bt.Task)(nil), db.ErrTaskNotFound)
+
+	tests := []struct {
+		name           string
+		id             string
+		expectedStatus int
+	}{
+		{name: "Succesfully restore task", id: "1", expectedStatus: http.StatusOK},
+		{name: "Task is not in trash", id: "2", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", "/tasks/"+tt.id+"/restore", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(handler.WithUserID(req.Context(), testUserID))
+			req = mux.SetURLVars(req, map[string]string{"id": tt.id})
+
+			rr := httptest.NewRecorder()
+			h.RestoreTaskHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if tt.expectedStatus == http.StatusOK && rr.Header().Get("ETag") != `"3"` {
+				t.Errorf(`Expected ETag "3", got %s`, rr.Header().Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestPurger(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+
+	t.Setenv("TRASH_RETENTION", "48h")
+	t.Setenv("TRASH_PURGE_INTERVAL", "")
+	store := blob.NewLocalStore(t.TempDir())
+	purger, err := db.NewPurgerFromEnv(mockDB, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purger.Retention != 48*time.Hour || purger.Interval != time.Hour {
+		t.Errorf("Unexpected purger config: retention %v, interval %v", purger.Retention, purger.Interval)
+	}
+
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	mockDB.On("PurgeDeletedTasks", now.Add(-48*time.Hour)).Return(int64(2), []string{"1/3/abc"}, nil)
+	if err := store.Put("1/3/abc", strings.NewReader("x"), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := purger.PurgeOnce(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 purged tasks, got %d", n)
+	}
+	if _, err := store.Open("1/3/abc"); !errors.Is(err, blob.ErrBlobNotFound) {
+		t.Errorf("Expected attachments of purged tasks to be deleted, got %v", err)
+	}
+
+	t.Setenv("TRASH_RETENTION", "forever")
+	if _, err := db.NewPurgerFromEnv(mockDB, nil); err == nil {
+		t.Errorf("Expected invalid retention to be rejected")
+	}
+}
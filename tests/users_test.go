@@ -0,0 +1,197 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"restapi/auth"
+	bt "restapi/basic_types"
+	"restapi/cache"
+	db "restapi/db"
+	"restapi/handler"
+	"restapi/tests/mocks"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/mock"
+)
+
+func withRole(req *http.Request, userID int, role string) *http.Request {
+	return req.WithContext(handler.WithClaims(req.Context(), &auth.Claims{UserID: userID, Role: role}))
+}
+
+func TestRequirePermission(t *testing.T) {
+	h := &handler.Handler{}
+
+	tests := []struct {
+		name           string
+		role           string
+		authenticated  bool
+		permission     auth.Permission
+		expectedStatus int
+	}{
+		{
+			name:           "Member can write own tasks",
+			role:           auth.RoleMember,
+			authenticated:  true,
+			permission:     auth.PermTasksWrite,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Member cannot manage users",
+			role:           auth.RoleMember,
+			authenticated:  true,
+			permission:     auth.PermUsersManage,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "Admin can manage users",
+			role:           auth.RoleAdmin,
+			authenticated:  true,
+			permission:     auth.PermUsersManage,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Unauthenticated request",
+			authenticated:  false,
+			permission:     auth.PermTasksRead,
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+			req, err := http.NewRequest("GET", "/users", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.authenticated {
+				req = withRole(req, testUserID, tt.role)
+			}
+
+			rr := httptest.NewRecorder()
+			h.RequirePermission(tt.permission)(next).ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestAdminTaskAccess(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	h := &handler.Handler{DB: mockDB, Cache: &mocks.MockTaskCache{}}
+
+	const otherUserID = 7
+
+	tests := []struct {
+		name           string
+		role           string
+		expectedStatus int
+	}{
+		{name: "Admin lists other user's tasks", role: auth.RoleAdmin, expectedStatus: http.StatusOK},
+		{name: "Member cannot list other user's tasks", role: auth.RoleMember, expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB.ExpectedCalls = nil
+			mockDB.Calls = nil
+			mockDB.On("GetAllTasks", mock.MatchedBy(func(query *db.TaskQuery) bool {
+				return query.OwnerID == otherUserID
+			})).Return([]bt.Task{}, "", nil)
+
+			req, err := http.NewRequest("GET", "/users/7/tasks", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = withRole(req, testUserID, tt.role)
+			req = mux.SetURLVars(req, map[string]string{"userID": "7"})
+
+			rr := httptest.NewRecorder()
+			h.GetAllTasksHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if tt.expectedStatus == http.StatusOK {
+				mockDB.AssertNumberOfCalls(t, "GetAllTasks", 1)
+			} else if len(mockDB.Calls) != 0 {
+				t.Errorf("Expected no DB calls, got %d", len(mockDB.Calls))
+			}
+		})
+	}
+}
+
+func TestUpdateUserRoleHandler(t *testing.T) {
+	mockDB := &mocks.MockTaskStore{}
+	h := &handler.Handler{DB: mockDB, Cache: &mocks.MockTaskCache{}, Revoked: cache.NewMemoryRevocationList()}
+
+	tests := []struct {
+		name           string
+		userID         string
+		role           string
+		dbUser         *db.User
+		dbError        error
+		expectedStatus int
+	}{
+		{
+			name:           "Succesfully promote user",
+			userID:         "7",
+			role:           auth.RoleAdmin,
+			dbUser:         &db.User{ID: 7, Login: "user", Role: auth.RoleAdmin},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Invalid role",
+			userID:         "7",
+			role:           "owner",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "User not found",
+			userID:         "100",
+			role:           auth.RoleMember,
+			dbUser:         nil,
+			dbError:        db.ErrUserNotFound,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB.ExpectedCalls = nil
+			mockDB.On("SetUserRole", mock.AnythingOfType("int"), tt.role).Return(tt.dbUser, tt.dbError)
+
+			body, _ := json.Marshal(map[string]string{"role": tt.role})
+
+			req, err := http.NewRequest("PUT", "/users/"+tt.userID+"/role", bytes.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = withRole(req, testUserID, auth.RoleAdmin)
+			req = mux.SetURLVars(req, map[string]string{"userID": tt.userID})
+
+			rr := httptest.NewRecorder()
+			h.UpdateUserRoleHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+
+			if tt.expectedStatus == http.StatusOK {
+				var user db.User
+				if err := json.NewDecoder(rr.Body).Decode(&user); err != nil {
+					t.Fatal(err)
+				}
+				if user.Role != tt.role {
+					t.Errorf("Expected role %s, got %s", tt.role, user.Role)
+				}
+			}
+		})
+	}
+}